@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	errNotLoggedIn    = errors.New("not logged in, run \"codepush login\" first")
+	errSessionExpired = errors.New("session expired, run \"codepush login\" again")
+)
+
+// Client calls the server's REST API
+type Client struct {
+	config *Config
+	http   *http.Client
+}
+
+func NewClient(config *Config) *Client {
+	return &Client{
+		config: config,
+		http:   &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// request sends a request authenticated with the saved access token. The body
+// is built by newBody, if given, so the request can be sent again.
+func (c *Client) request(method, path string, newBody func() (io.Reader, string)) (*http.Response, error) {
+	var body io.Reader
+	var contentType string
+	if newBody != nil {
+		body, contentType = newBody()
+	}
+
+	req, err := http.NewRequest(method, c.config.ServerURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+	return c.http.Do(req)
+}
+
+// do sends a request and decodes the JSON response into out. A request
+// rejected because the access token expired is sent again once after
+// refreshing it. Error responses are returned as errors carrying the server's
+// message.
+func (c *Client) do(method, path string, newBody func() (io.Reader, string), out interface{}) error {
+	resp, err := c.request(method, path, newBody)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.config.RefreshToken != "" {
+		resp.Body.Close()
+		if err := c.refresh(); err != nil {
+			return err
+		}
+		if resp, err = c.request(method, path, newBody); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return responseError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// refresh exchanges the saved refresh token for a new token pair and saves it.
+// A refresh token the server no longer accepts is forgotten.
+func (c *Client) refresh() error {
+	data, err := json.Marshal(map[string]string{"refresh_token": c.config.RefreshToken})
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(http.MethodPost, "/api/v1/auth/refresh", func() (io.Reader, string) {
+		return bytes.NewReader(data), "application/json"
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.config.Token, c.config.RefreshToken = "", ""
+		if err := saveConfig(c.config); err != nil {
+			return err
+		}
+		return errSessionExpired
+	}
+	if resp.StatusCode >= 400 {
+		return responseError(resp)
+	}
+
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return err
+	}
+	c.config.Token, c.config.RefreshToken = tokens.Token, tokens.RefreshToken
+	return saveConfig(c.config)
+}
+
+// responseError returns an error carrying the message of an error response
+func responseError(resp *http.Response) error {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+		return fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+	}
+	return fmt.Errorf("request failed with HTTP %d", resp.StatusCode)
+}
+
+func (c *Client) get(path string, query url.Values, out interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+func (c *Client) send(method, path string, in, out interface{}) error {
+	if in == nil {
+		return c.do(method, path, nil, out)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, func() (io.Reader, string) {
+		return bytes.NewReader(data), "application/json"
+	}, out)
+}
+
+// upload posts a file as the multipart field "package" along with form fields,
+// streaming it rather than buffering it in memory
+func (c *Client) upload(path, filePath string, fields map[string]string, out interface{}) error {
+	// Errors opening the file inside the body would only fail the request
+	if _, err := os.Stat(filePath); err != nil {
+		return err
+	}
+
+	return c.do(http.MethodPost, path, func() (io.Reader, string) {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		go func() {
+			f, err := os.Open(filePath)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			defer f.Close()
+
+			for name, value := range fields {
+				if err := writer.WriteField(name, value); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			part, err := writer.CreateFormFile("package", filepath.Base(filePath))
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(part, f); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(writer.Close())
+		}()
+		return pr, writer.FormDataContentType()
+	}, out)
+}
+
+// App is an app as returned by the API
+type App struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Deployment is a deployment as returned by the API
+type Deployment struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// Release is a release as returned by the API
+type Release struct {
+	Label                    string    `json:"label"`
+	AppVersion               string    `json:"app_version"`
+	Description              string    `json:"description"`
+	IsMandatory              bool      `json:"is_mandatory"`
+	IsDisabled               bool      `json:"is_disabled"`
+	PackageHash              string    `json:"package_hash"`
+	Size                     int64     `json:"size"`
+	Rollout                  int       `json:"rollout"`
+	ReleaseMethod            string    `json:"release_method"`
+	OriginalLabel            string    `json:"original_label"`
+	ReleasedByEmail          string    `json:"released_by_email"`
+	ReleasedByServiceAccount string    `json:"released_by_service_account"`
+	CreatedAt                time.Time `json:"created_at"`
+}
+
+// resolveApp accepts either an app ID or an app name
+func (c *Client) resolveApp(nameOrID string) (string, error) {
+	var resp struct {
+		Apps []App `json:"apps"`
+	}
+	if err := c.get("/api/v1/user/apps", nil, &resp); err != nil {
+		return "", err
+	}
+	for _, app := range resp.Apps {
+		if app.ID == nameOrID || app.Name == nameOrID {
+			return app.ID, nil
+		}
+	}
+	return "", fmt.Errorf("app %q not found", nameOrID)
+}
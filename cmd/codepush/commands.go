@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// CLI implements the codepush commands
+type CLI struct {
+	config *Config
+	client *Client
+}
+
+// parseFlags parses a command's flags, allowing them before, between and
+// after positional arguments, and checks the number of positional arguments
+func parseFlags(fs *flag.FlagSet, args []string, want int, usage string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != want {
+		return nil, fmt.Errorf("usage: codepush %s", usage)
+	}
+	return positional, nil
+}
+
+// flagSet reports whether a flag was given on the command line
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func (cli *CLI) requireLogin() error {
+	if cli.config.Token == "" {
+		return errNotLoggedIn
+	}
+	return nil
+}
+
+func (cli *CLI) subcommand(args []string, commands map[string]func([]string) error) error {
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			if err := cli.requireLogin(); err != nil {
+				return err
+			}
+			return command(args[1:])
+		}
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("expected one of: %s", strings.Join(names, ", "))
+}
+
+func prompt(reader *bufio.Reader, label string) (string, error) {
+	fmt.Print(label)
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptPassword reads a password without echoing it when stdin is a
+// terminal
+func promptPassword(reader *bufio.Reader, label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return prompt(reader, label)
+	}
+
+	fmt.Print(label)
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+func (cli *CLI) login(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	serverURL := fs.String("server", cli.config.ServerURL, "server URL")
+	email := fs.String("email", "", "account email")
+	password := fs.String("password", "", "account password (prompted for if omitted)")
+	code := fs.String("code", "", "two-factor or recovery code (prompted for if required and omitted)")
+	if _, err := parseFlags(fs, args, 0, "login [-server URL] [-email EMAIL] [-password PASSWORD] [-code CODE]"); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var err error
+	if *email == "" {
+		if *email, err = prompt(reader, "Email: "); err != nil {
+			return err
+		}
+	}
+	if *password == "" {
+		if *password, err = promptPassword(reader, "Password: "); err != nil {
+			return err
+		}
+	}
+
+	cli.config.ServerURL = strings.TrimSuffix(*serverURL, "/")
+	cli.config.Token, cli.config.RefreshToken = "", ""
+
+	var resp struct {
+		Token             string `json:"token"`
+		RefreshToken      string `json:"refresh_token"`
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	if err := cli.client.send(http.MethodPost, "/api/v1/auth/login", map[string]string{
+		"email":    *email,
+		"password": *password,
+	}, &resp); err != nil {
+		return err
+	}
+
+	if resp.TwoFactorRequired {
+		if *code == "" {
+			if *code, err = prompt(reader, "Two-factor code: "); err != nil {
+				return err
+			}
+		}
+		if err := cli.client.send(http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+			"challenge_token": resp.ChallengeToken,
+			"code":            *code,
+		}, &resp); err != nil {
+			return err
+		}
+	}
+
+	cli.config.Token, cli.config.RefreshToken = resp.Token, resp.RefreshToken
+	if err := saveConfig(cli.config); err != nil {
+		return err
+	}
+
+	fmt.Printf("Logged in to %s as %s\n", cli.config.ServerURL, *email)
+	return nil
+}
+
+// logout ends the session on the server before forgetting the tokens, so
+// copies of them stop working too
+func (cli *CLI) logout(args []string) error {
+	if cli.config.Token != "" {
+		if err := cli.client.send(http.MethodPost, "/api/v1/auth/logout", map[string]string{
+			"refresh_token": cli.config.RefreshToken,
+		}, nil); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: could not end the session on the server:", err)
+		}
+	}
+
+	cli.config.Token, cli.config.RefreshToken = "", ""
+	if err := saveConfig(cli.config); err != nil {
+		return err
+	}
+	fmt.Println("Logged out")
+	return nil
+}
+
+func (cli *CLI) appAdd(args []string) error {
+	fs := flag.NewFlagSet("app add", flag.ExitOnError)
+	description := fs.String("description", "", "app description")
+	positional, err := parseFlags(fs, args, 1, "app add <name> [-description TEXT]")
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		App struct {
+			App
+			Deployments []Deployment `json:"deployments"`
+		} `json:"app"`
+	}
+	if err := cli.client.send(http.MethodPost, "/api/v1/user/apps", map[string]string{
+		"name":        positional[0],
+		"description": *description,
+	}, &resp); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created app %s (%s)\n", resp.App.Name, resp.App.ID)
+	printDeployments(resp.App.Deployments)
+	return nil
+}
+
+func (cli *CLI) appList(args []string) error {
+	fs := flag.NewFlagSet("app ls", flag.ExitOnError)
+	if _, err := parseFlags(fs, args, 0, "app ls"); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Apps []App `json:"apps"`
+	}
+	if err := cli.client.get("/api/v1/user/apps", nil, &resp); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tDESCRIPTION")
+	for _, app := range resp.Apps {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", app.Name, app.ID, app.Description)
+	}
+	return w.Flush()
+}
+
+func (cli *CLI) appRemove(args []string) error {
+	fs := flag.NewFlagSet("app rm", flag.ExitOnError)
+	positional, err := parseFlags(fs, args, 1, "app rm <app>")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+	if err := cli.client.send(http.MethodDelete, "/api/v1/user/apps/"+url.PathEscape(appID), nil, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed app %s\n", positional[0])
+	return nil
+}
+
+func printDeployments(deployments []Deployment) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPLOYMENT\tKEY")
+	for _, deployment := range deployments {
+		fmt.Fprintf(w, "%s\t%s\n", deployment.Name, deployment.Key)
+	}
+	w.Flush()
+}
+
+func (cli *CLI) deploymentAdd(args []string) error {
+	fs := flag.NewFlagSet("deployment add", flag.ExitOnError)
+	positional, err := parseFlags(fs, args, 2, "deployment add <app> <name>")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Deployment Deployment `json:"deployment"`
+	}
+	if err := cli.client.send(http.MethodPost, "/api/v1/user/apps/"+url.PathEscape(appID)+"/deployments", map[string]string{
+		"name": positional[1],
+	}, &resp); err != nil {
+		return err
+	}
+
+	printDeployments([]Deployment{resp.Deployment})
+	return nil
+}
+
+func (cli *CLI) deploymentList(args []string) error {
+	fs := flag.NewFlagSet("deployment ls", flag.ExitOnError)
+	positional, err := parseFlags(fs, args, 1, "deployment ls <app>")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Deployments []Deployment `json:"deployments"`
+	}
+	if err := cli.client.get("/api/v1/user/apps/"+url.PathEscape(appID)+"/deployments", nil, &resp); err != nil {
+		return err
+	}
+
+	printDeployments(resp.Deployments)
+	return nil
+}
+
+func deploymentPath(appID, deployment string) string {
+	return "/api/v1/apps/" + url.PathEscape(appID) + "/deployments/" + url.PathEscape(deployment)
+}
+
+func printRelease(verb string, release *Release) {
+	fmt.Printf("%s %s (%s, %d bytes, target %s, rollout %d%%)\n",
+		verb, release.Label, release.PackageHash, release.Size, release.AppVersion, release.Rollout)
+}
+
+func (cli *CLI) release(args []string) error {
+	if err := cli.requireLogin(); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	deployment := fs.String("deployment", "Staging", "deployment to release to")
+	description := fs.String("description", "", "release description")
+	mandatory := fs.Bool("mandatory", false, "require clients to install the update")
+	rollout := fs.Int("rollout", 100, "percentage of clients to receive the update")
+	positional, err := parseFlags(fs, args, 3, "release <app> <path> <target-binary-version> [-deployment NAME] [-description TEXT] [-mandatory] [-rollout PERCENT]")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	pkg, temporary, err := zipPackage(positional[1])
+	if err != nil {
+		return err
+	}
+	if temporary {
+		defer os.Remove(pkg)
+	}
+
+	var resp struct {
+		Release Release `json:"release"`
+	}
+	if err := cli.client.upload(deploymentPath(appID, *deployment)+"/releases", pkg, map[string]string{
+		"app_version":  positional[2],
+		"description":  *description,
+		"is_mandatory": strconv.FormatBool(*mandatory),
+		"rollout":      strconv.Itoa(*rollout),
+	}, &resp); err != nil {
+		return err
+	}
+
+	printRelease("Released", &resp.Release)
+	return nil
+}
+
+func (cli *CLI) promote(args []string) error {
+	if err := cli.requireLogin(); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	label := fs.String("label", "", "release to promote (defaults to the latest)")
+	description := fs.String("description", "", "override the release description")
+	mandatory := fs.Bool("mandatory", false, "override the mandatory flag")
+	rollout := fs.Int("rollout", 100, "override the rollout percentage")
+	positional, err := parseFlags(fs, args, 3, "promote <app> <source-deployment> <destination-deployment> [-label LABEL] [-description TEXT] [-mandatory] [-rollout PERCENT]")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	// Only flags given on the command line override the promoted release
+	body := map[string]interface{}{"label": *label}
+	if flagSet(fs, "description") {
+		body["description"] = *description
+	}
+	if flagSet(fs, "mandatory") {
+		body["is_mandatory"] = *mandatory
+	}
+	if flagSet(fs, "rollout") {
+		body["rollout"] = *rollout
+	}
+
+	var resp struct {
+		Release Release `json:"release"`
+	}
+	if err := cli.client.send(http.MethodPost, deploymentPath(appID, positional[1])+"/promote/"+url.PathEscape(positional[2]), body, &resp); err != nil {
+		return err
+	}
+
+	printRelease("Promoted to "+positional[2]+" as", &resp.Release)
+	return nil
+}
+
+func (cli *CLI) rollback(args []string) error {
+	if err := cli.requireLogin(); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	label := fs.String("label", "", "release to roll back to (defaults to the previous one)")
+	positional, err := parseFlags(fs, args, 2, "rollback <app> <deployment> [-label LABEL]")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	path := deploymentPath(appID, positional[1]) + "/rollback"
+	if *label != "" {
+		path += "/" + url.PathEscape(*label)
+	}
+
+	var resp struct {
+		Release Release `json:"release"`
+	}
+	if err := cli.client.send(http.MethodPost, path, nil, &resp); err != nil {
+		return err
+	}
+
+	printRelease("Rolled back "+positional[1]+" to "+resp.Release.OriginalLabel+" as", &resp.Release)
+	return nil
+}
+
+func (cli *CLI) history(args []string) error {
+	if err := cli.requireLogin(); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	page := fs.Int("page", 1, "page number")
+	perPage := fs.Int("per-page", 20, "releases per page")
+	positional, err := parseFlags(fs, args, 2, "history <app> <deployment> [-page N] [-per-page N]")
+	if err != nil {
+		return err
+	}
+
+	appID, err := cli.client.resolveApp(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Releases []Release `json:"releases"`
+		Total    int64     `json:"total"`
+	}
+	if err := cli.client.get(deploymentPath(appID, positional[1])+"/history", url.Values{
+		"page":     {strconv.Itoa(*page)},
+		"per_page": {strconv.Itoa(*perPage)},
+	}, &resp); err != nil {
+		return err
+	}
+	if len(resp.Releases) == 0 {
+		fmt.Println("No releases")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LABEL\tTARGET\tMETHOD\tMANDATORY\tDISABLED\tROLLOUT\tSIZE\tRELEASED BY\tRELEASED AT\tDESCRIPTION")
+	for _, release := range resp.Releases {
+		method := release.ReleaseMethod
+		if release.OriginalLabel != "" {
+			method += " (" + release.OriginalLabel + ")"
+		}
+		releasedBy := release.ReleasedByEmail
+		if release.ReleasedByServiceAccount != "" {
+			releasedBy = release.ReleasedByServiceAccount + " (service account)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%d%%\t%d\t%s\t%s\t%s\n",
+			release.Label, release.AppVersion, method, release.IsMandatory, release.IsDisabled,
+			release.Rollout, release.Size, releasedBy,
+			release.CreatedAt.Local().Format(time.DateTime), release.Description)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Page %d, %d releases in total\n", *page, resp.Total)
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultServerURL = "http://localhost:8080"
+
+// Config holds the server the CLI talks to and the credentials stored by login
+type Config struct {
+	ServerURL    string `json:"server_url"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"` // renews Token when it expires
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("CODEPUSH_CONFIG"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".codepush.json"), nil
+}
+
+// loadConfig reads the saved configuration. CODEPUSH_SERVER_URL and
+// CODEPUSH_TOKEN override it, so CI pipelines can run without logging in.
+func loadConfig() (*Config, error) {
+	config := &Config{ServerURL: defaultServerURL}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if serverURL := os.Getenv("CODEPUSH_SERVER_URL"); serverURL != "" {
+		config.ServerURL = serverURL
+	}
+	// Access keys do not expire, so the saved refresh token is not used
+	if token := os.Getenv("CODEPUSH_TOKEN"); token != "" {
+		config.Token = token
+		config.RefreshToken = ""
+	}
+	config.ServerURL = strings.TrimSuffix(config.ServerURL, "/")
+
+	return config, nil
+}
+
+// saveConfig writes the configuration readable by the current user only,
+// since it contains the tokens
+func saveConfig(config *Config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,80 @@
+// Command codepush manages apps, deployments and releases on a CodePush server
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: codepush <command> [arguments]
+
+Commands:
+  login [-server URL] [-email EMAIL] [-password PASSWORD] [-code CODE]
+  logout
+  app add <name> [-description TEXT]
+  app ls
+  app rm <app>
+  deployment add <app> <name>
+  deployment ls <app>
+  release <app> <path> <target-binary-version> [-deployment NAME] [-description TEXT] [-mandatory] [-rollout PERCENT]
+  promote <app> <source-deployment> <destination-deployment> [-label LABEL] [-description TEXT] [-mandatory] [-rollout PERCENT]
+  rollback <app> <deployment> [-label LABEL]
+  history <app> <deployment> [-page N] [-per-page N]
+
+Apps can be referred to by name or ID. The server and token saved by login
+can be overridden with CODEPUSH_SERVER_URL and CODEPUSH_TOKEN.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fatal(err)
+	}
+	cli := &CLI{config: config, client: NewClient(config)}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "login":
+		err = cli.login(args)
+	case "logout":
+		err = cli.logout(args)
+	case "app":
+		err = cli.subcommand(args, map[string]func([]string) error{
+			"add": cli.appAdd,
+			"ls":  cli.appList,
+			"rm":  cli.appRemove,
+		})
+	case "deployment":
+		err = cli.subcommand(args, map[string]func([]string) error{
+			"add": cli.deploymentAdd,
+			"ls":  cli.deploymentList,
+		})
+	case "release":
+		err = cli.release(args)
+	case "promote":
+		err = cli.promote(args)
+	case "rollback":
+		err = cli.rollback(args)
+	case "history":
+		err = cli.history(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
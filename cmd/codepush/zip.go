@@ -0,0 +1,69 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// zipPackage writes the update contents at path into a temporary zip file and
+// returns its name. A directory is stored under its own name, as the CodePush
+// CLI does, so "build/CodePush" becomes "CodePush/..." inside the archive.
+// Existing zip files are uploaded unchanged.
+func zipPackage(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".zip") {
+		return path, false, nil
+	}
+
+	tmp, err := os.CreateTemp("", "codepush-release-*.zip")
+	if err != nil {
+		return "", false, err
+	}
+	defer tmp.Close()
+
+	writer := zip.NewWriter(tmp)
+	base := filepath.Dir(filepath.Clean(path))
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		header.Method = zip.Deflate
+
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", false, err
+	}
+
+	return tmp.Name(), true, nil
+}
@@ -3,35 +3,108 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
 	// Server configuration
-	Port     int    `json:"port"`
-	Host     string `json:"host"`
-	DBType   string `json:"db_type"`
-	DBHost   string `json:"db_host"`
-	DBPort   int    `json:"db_port"`
-	DBUser   string `json:"db_user"`
+	Port       int    `json:"port"`
+	Host       string `json:"host"`
+	ServerURL  string `json:"server_url"` // public base URL used in download links
+	DBType     string `json:"db_type"`
+	DBHost     string `json:"db_host"`
+	DBPort     int    `json:"db_port"`
+	DBUser     string `json:"db_user"`
 	DBPassword string `json:"db_password"`
-	DBName   string `json:"db_name"`
-	JWTKey   string `json:"jwt_key"`
-	JWTExpiry int    `json:"jwt_expiry"`
+	DBName     string `json:"db_name"`
+
+	// Proxies whose X-Forwarded-For header gives the client IP, which sign in
+	// attempts are throttled by. Without any, the connection's address is used.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// Token signing configuration
+	JWTAlgorithm  string `json:"jwt_algorithm"`   // "HS256", "RS256" or "EdDSA"
+	JWTKey        string `json:"jwt_key"`         // HS256 secret
+	JWTKeyFile    string `json:"jwt_key_file"`    // file containing the HS256 secret or the PEM private key
+	JWTKeyID      string `json:"jwt_key_id"`      // kid header of issued tokens
+	JWTVerifyKeys string `json:"jwt_verify_keys"` // comma-separated kid=file pairs of keys still accepted
+	JWTExpiry     int    `json:"jwt_expiry"`      // hours
+	RefreshExpiry int    `json:"refresh_expiry"`  // days
+	TOTPIssuer    string `json:"totp_issuer"`     // account issuer shown in authenticator apps
+
+	// Single sign-on configuration
+	OIDCProviders        []OIDCProvider `json:"oidc_providers"`
+	OIDCAllowedRedirects []string       `json:"oidc_allowed_redirects"` // URLs, or paths below them, tokens may be redirected to after sign in
+
+	// Email configuration
+	MailerType   string `json:"mailer_type"` // "log", "file" or "smtp"
+	MailFrom     string `json:"mail_from"`
+	MailDir      string `json:"mail_dir"` // directory the file mailer writes to
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+	AppURL       string `json:"app_url"` // base URL of the web app that email links open
+
+	// Storage configuration
+	StorageType           string `json:"storage_type"` // "local" or "s3"
+	StoragePath           string `json:"storage_path"`
+	SignedURLExpiry       int    `json:"signed_url_expiry"` // minutes
+	S3Endpoint            string `json:"s3_endpoint"`
+	S3Region              string `json:"s3_region"`
+	S3Bucket              string `json:"s3_bucket"`
+	S3AccessKey           string `json:"s3_access_key"`
+	S3SecretKey           string `json:"s3_secret_key"`
+	S3UsePathStyle        bool   `json:"s3_use_path_style"`
+	S3ForbiddenAsNotFound bool   `json:"s3_forbidden_as_not_found"` // for credentials without ListBucket permission
 }
 
 func NewConfig() *Config {
 	port, _ := strconv.Atoi(getEnv("DB_PORT", "5432"))
 	return &Config{
-		Port:     8080,
-		Host:     "localhost",
-		DBType:   getEnv("DB_TYPE", "postgres"),
-		DBHost:   getEnv("DB_HOST", "localhost"),
-		DBPort:   port,
-		DBUser:   getEnv("DB_USER", "postgres"),
+		Port:       8080,
+		Host:       "localhost",
+		ServerURL:  getEnv("SERVER_URL", "http://localhost:8080"),
+		DBType:     getEnv("DB_TYPE", "postgres"),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     port,
+		DBUser:     getEnv("DB_USER", "postgres"),
 		DBPassword: getEnv("DB_PASSWORD", "postgres"),
-		DBName:   getEnv("DB_NAME", "codepush"),
-		JWTKey:   "your-secret-key",
-		JWTExpiry: 24, // hours
+		DBName:     getEnv("DB_NAME", "codepush"),
+
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"),
+
+		JWTAlgorithm:  getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKey:        getEnv("JWT_SECRET", ""),
+		JWTKeyFile:    getEnv("JWT_KEY_FILE", ""),
+		JWTKeyID:      getEnv("JWT_KEY_ID", ""),
+		JWTVerifyKeys: getEnv("JWT_VERIFY_KEYS", ""),
+		JWTExpiry:     getEnvAsInt("JWT_EXPIRY", 24),
+		RefreshExpiry: getEnvAsInt("REFRESH_TOKEN_EXPIRY", 30),
+		TOTPIssuer:    getEnv("TOTP_ISSUER", "CodePush"),
+
+		OIDCProviders:        loadOIDCProviders(),
+		OIDCAllowedRedirects: getEnvAsList("OIDC_ALLOWED_REDIRECTS"),
+
+		MailerType:   getEnv("MAILER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "CodePush <noreply@localhost>"),
+		MailDir:      getEnv("MAIL_DIR", "./mail"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		AppURL:       getEnv("APP_URL", "http://localhost:3000"),
+
+		StorageType:           getEnv("STORAGE_TYPE", "local"),
+		StoragePath:           getEnv("STORAGE_PATH", "./data"),
+		SignedURLExpiry:       getEnvAsInt("SIGNED_URL_EXPIRY", 60),
+		S3Endpoint:            getEnv("S3_ENDPOINT", ""),
+		S3Region:              getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:              getEnv("S3_BUCKET", ""),
+		S3AccessKey:           getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:           getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle:        getEnv("S3_USE_PATH_STYLE", "true") == "true",
+		S3ForbiddenAsNotFound: getEnv("S3_FORBIDDEN_AS_NOT_FOUND", "false") == "true",
 	}
 }
 
@@ -43,6 +116,16 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	value := os.Getenv(key)
 	if value == "" {
@@ -53,4 +136,67 @@ func getEnvAsInt(key string, defaultValue int) int {
 		return defaultValue
 	}
 	return intValue
-} 
\ No newline at end of file
+}
+
+// OIDCProvider configures sign in with an external identity provider. OpenID
+// Connect providers only need an issuer, their endpoints are discovered.
+// Plain OAuth2 providers such as GitHub need explicit endpoints instead.
+type OIDCProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	AuthURL      string   `json:"auth_url"`
+	TokenURL     string   `json:"token_url"`
+	UserInfoURL  string   `json:"userinfo_url"`
+	EmailsURL    string   `json:"emails_url"` // GitHub style list of the user's emails and whether they are verified
+	JWKSURL      string   `json:"jwks_url"`
+}
+
+// oidcPresets holds the settings of well-known providers
+var oidcPresets = map[string]OIDCProvider{
+	"google": {
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"github": {
+		Scopes:      []string{"read:user", "user:email"},
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+	},
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS. Each one is
+// configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _SCOPES,
+// _AUTH_URL, _TOKEN_URL, _USERINFO_URL, _EMAILS_URL and _JWKS_URL, on top of
+// the preset for well-known providers.
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getEnvAsList("OIDC_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		provider := oidcPresets[name]
+		provider.Name = name
+		provider.Issuer = getEnv(prefix+"ISSUER", provider.Issuer)
+		provider.ClientID = getEnv(prefix+"CLIENT_ID", "")
+		provider.ClientSecret = getEnv(prefix+"CLIENT_SECRET", "")
+		provider.AuthURL = getEnv(prefix+"AUTH_URL", provider.AuthURL)
+		provider.TokenURL = getEnv(prefix+"TOKEN_URL", provider.TokenURL)
+		provider.UserInfoURL = getEnv(prefix+"USERINFO_URL", provider.UserInfoURL)
+		provider.EmailsURL = getEnv(prefix+"EMAILS_URL", provider.EmailsURL)
+		provider.JWKSURL = getEnv(prefix+"JWKS_URL", provider.JWKSURL)
+		if scopes := getEnvAsList(prefix + "SCOPES"); scopes != nil {
+			provider.Scopes = scopes
+		}
+		if provider.Scopes == nil {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers = append(providers, provider)
+	}
+	return providers
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/piyushsharma67/codepushserver/config"
 	"github.com/piyushsharma67/codepushserver/models"
@@ -17,10 +19,64 @@ type Database interface {
 	FindUserByEmail(email string) (*models.User, error)
 	UpdateUser(user *models.User) error
 
+	// Access key methods
+	CreateAccessKey(key *models.AccessKey) error
+	FindAccessKeyByID(id uint) (*models.AccessKey, error)
+	FindAccessKeyByHash(keyHash string) (*models.AccessKey, error)
+	FindAccessKeysByUserID(userID uint) ([]*models.AccessKey, error)
+	UpdateAccessKey(key *models.AccessKey) error
+	TouchAccessKey(id uint, usedAt time.Time) error
+
+	// Token methods
+	CreateRefreshToken(token *models.RefreshToken) error
+	FindRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(id uint, usedAt time.Time) (bool, error)
+	RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) error
+	RevokeUserRefreshTokens(userID uint, revokedAt time.Time) error
+	RevokeToken(revoked *models.RevokedToken) error
+	IsTokenRevoked(tokenID string) (bool, error)
+	CreateUserToken(token *models.UserToken) error
+	FindUserTokenByHash(tokenHash string) (*models.UserToken, error)
+	MarkUserTokenUsed(id uint, usedAt time.Time) (bool, error)
+
+	// Session methods
+	CreateSession(session *models.Session) error
+	FindSessionByID(id string) (*models.Session, error)
+	FindActiveSessionsByUserID(userID uint) ([]*models.Session, error)
+	TouchSession(id, ip string, usedAt time.Time) error
+	ExtendSession(id, ip string, usedAt, expiresAt time.Time) error
+	RevokeSession(id string, revokedAt time.Time) error
+	RevokeUserSessions(userID uint, revokedAt time.Time) error
+
+	// Identity methods
+	CreateUserIdentity(identity *models.UserIdentity) error
+	CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error
+	FindUserIdentity(provider, subject string) (*models.UserIdentity, error)
+	CreateOIDCLoginState(state *models.OIDCLoginState) error
+	ConsumeOIDCLoginState(state string) (*models.OIDCLoginState, error)
+
+	// Two-factor methods
+	UpdateTOTPLastStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codes []*models.RecoveryCode) error
+	FindRecoveryCodesByUserID(userID uint) ([]*models.RecoveryCode, error)
+	UseRecoveryCode(userID uint, codeHash string, usedAt time.Time) (bool, error)
+	CreateLoginChallenge(challenge *models.LoginChallenge) error
+	FindLoginChallengeByHash(tokenHash string) (*models.LoginChallenge, error)
+	IncrementLoginChallengeAttempts(id uint) error
+	DeleteLoginChallenge(id uint) (bool, error)
+
+	// Login event methods
+	CreateLoginEvent(event *models.LoginEvent) error
+	FindLoginEventsByEmail(email string, results []string, since time.Time, limit int) ([]*models.LoginEvent, error)
+	FindLoginEventsByIP(ip string, results []string, since time.Time, limit int) ([]*models.LoginEvent, error)
+	UpdateLoginEventResult(id uint, result string) error
+	FindLoginEventsByUserID(userID uint, limit int) ([]*models.LoginEvent, error)
+
 	// Organization methods
 	CreateOrganization(org *models.Organization) error
 	FindOrganizationByID(id uuid.UUID) (*models.Organization, error)
 	FindOrganizationsByUserID(userID uint) ([]*models.Organization, error)
+	UpdateOrganization(org *models.Organization) error
 	DeleteOrganization(id uuid.UUID) error
 
 	// Organization member methods
@@ -30,9 +86,22 @@ type Database interface {
 
 	// Organization invitation methods
 	CreateOrganizationInvitation(invitation *models.OrganizationInvitation) error
-	FindOrganizationInvitationByID(id uuid.UUID) (*models.OrganizationInvitation, error)
+	FindOrganizationInvitationByID(id uint) (*models.OrganizationInvitation, error)
 	FindPendingInvitationsByEmail(email string) ([]*models.OrganizationInvitation, error)
 	UpdateOrganizationInvitation(invitation *models.OrganizationInvitation) error
+	AcceptOrganizationInvitation(id uint, member *models.OrganizationMember) (bool, error)
+	DeclineOrganizationInvitation(id uint) (bool, error)
+
+	// Service account methods
+	CreateServiceAccount(account *models.ServiceAccount, user *models.User, member *models.OrganizationMember) error
+	FindServiceAccountByID(id uint) (*models.ServiceAccount, error)
+	FindServiceAccountByUserID(userID uint) (*models.ServiceAccount, error)
+	FindServiceAccountsByOrganizationID(orgID uuid.UUID) ([]*models.ServiceAccount, error)
+	DeleteServiceAccount(account *models.ServiceAccount, deletedAt time.Time) error
+	CreateServiceAccountGrant(grant *models.ServiceAccountGrant) error
+	FindServiceAccountGrantByID(id uint) (*models.ServiceAccountGrant, error)
+	FindServiceAccountGrants(serviceAccountID uint) ([]*models.ServiceAccountGrant, error)
+	DeleteServiceAccountGrant(id uint) error
 
 	// App methods
 	CreateApp(app *models.App) error
@@ -40,6 +109,36 @@ type Database interface {
 	FindAppsByUserID(userID uint) ([]*models.App, error)
 	UpdateApp(app *models.App) error
 	DeleteApp(id string) error
+
+	// Deployment methods
+	CreateDeployment(deployment *models.Deployment) error
+	FindDeploymentByName(appID, name string) (*models.Deployment, error)
+	FindDeploymentByKey(key string) (*models.Deployment, error)
+	FindDeploymentsByAppID(appID string) ([]*models.Deployment, error)
+	UpdateDeployment(deployment *models.Deployment) error
+	DeleteDeployment(id uint) error
+
+	// Release methods
+	CreateRelease(release *models.Release) error
+	FindLatestRelease(deploymentID uint) (*models.Release, error)
+	FindReleasesByDeploymentID(deploymentID uint) ([]*models.Release, error)
+	FindReleasesPage(deploymentID uint, offset, limit int) ([]*models.Release, int64, error)
+	FindReleaseByLabel(deploymentID uint, label string) (*models.Release, error)
+	FindReleaseByPackageHash(appID, packageHash string) (*models.Release, error)
+	FindReleaseByBlobPath(blobPath string) (*models.Release, error)
+	UpdateRelease(release *models.Release) error
+
+	// Package diff methods
+	CreatePackageDiff(diff *models.PackageDiff) error
+	FindPackageDiff(fromPackageHash, toBlobPath string) (*models.PackageDiff, error)
+	FindPackageDiffByBlobPath(blobPath string) (*models.PackageDiff, error)
+
+	// Status report methods
+	CreateStatusReport(report *models.StatusReport) error
+
+	// Release metric methods
+	IncrementReleaseMetric(deploymentID uint, label, metric string, delta int) error
+	FindReleaseMetrics(deploymentID uint) ([]*models.ReleaseMetric, error)
 }
 
 // NewDatabase creates a new database instance based on the configuration
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recorder is a database/sql connector that logs every statement instead of
+// running it. Queries return a single deployment row and statements affect
+// no rows.
+type recorder struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *recorder) log(statement string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, statement)
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return nil }
+
+type recorderConn struct{ r *recorder }
+
+func (c recorderConn) Prepare(query string) (driver.Stmt, error) { return recorderStmt{c.r, query}, nil }
+func (c recorderConn) Close() error                              { return nil }
+
+func (c recorderConn) Begin() (driver.Tx, error) {
+	c.r.log("BEGIN")
+	return c, nil
+}
+
+func (c recorderConn) Commit() error {
+	c.r.log("COMMIT")
+	return nil
+}
+
+func (c recorderConn) Rollback() error {
+	c.r.log("ROLLBACK")
+	return nil
+}
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s recorderStmt) Close() error  { return nil }
+func (s recorderStmt) NumInput() int { return -1 }
+
+func (s recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.log(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.r.log(s.query)
+	return &deploymentRows{}, nil
+}
+
+type deploymentRows struct{ done bool }
+
+func (r *deploymentRows) Columns() []string { return []string{"id", "app_id", "name"} }
+func (r *deploymentRows) Close() error      { return nil }
+
+func (r *deploymentRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1], dest[2] = int64(1), "app", "Staging"
+	return nil
+}
+
+func openRecorded(t *testing.T, dialect string) (Database, *recorder) {
+	r := &recorder{}
+	conn := sql.OpenDB(r)
+
+	var dialector gorm.Dialector
+	var open func(*gorm.DB) Database
+	switch dialect {
+	case "postgres":
+		dialector = postgres.New(postgres.Config{Conn: conn})
+		open = func(db *gorm.DB) Database { return &PostgresDB{db: db} }
+	case "mysql":
+		dialector = mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true})
+		open = func(db *gorm.DB) Database { return &MySQLDB{db: db} }
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return open(db), r
+}
+
+// deletedTables returns the tables deleted from inside the first transaction
+func deletedTables(t *testing.T, statements []string) []string {
+	var tables []string
+	inTransaction := false
+	for _, statement := range statements {
+		switch {
+		case statement == "BEGIN":
+			inTransaction = true
+		case statement == "COMMIT":
+			return tables
+		case strings.HasPrefix(statement, "DELETE FROM "):
+			if !inTransaction {
+				t.Errorf("%s ran outside the transaction", statement)
+			}
+			table := strings.Fields(statement)[2]
+			tables = append(tables, strings.Trim(table, "\"`"))
+		}
+	}
+	t.Fatalf("transaction was not committed: %q", statements)
+	return nil
+}
+
+func TestDeleteCascades(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete func(Database) error
+		want   []string
+	}{
+		{
+			name:   "app",
+			delete: func(db Database) error { return db.DeleteApp("app") },
+			want: []string{"status_reports", "release_metrics", "releases", "package_diffs",
+				"service_account_grants", "deployments", "apps"},
+		},
+		{
+			name:   "deployment",
+			delete: func(db Database) error { return db.DeleteDeployment(1) },
+			want: []string{"status_reports", "release_metrics", "releases", "package_diffs",
+				"service_account_grants", "deployments"},
+		},
+	}
+
+	for _, dialect := range []string{"postgres", "mysql"} {
+		for _, tt := range tests {
+			t.Run(dialect+" "+tt.name, func(t *testing.T) {
+				db, r := openRecorded(t, dialect)
+				if err := tt.delete(db); err != nil {
+					t.Fatalf("delete returned error: %v", err)
+				}
+
+				got := deletedTables(t, r.statements)
+				if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+					t.Errorf("deleted from %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -2,12 +2,14 @@ package database
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/piyushsharma67/codepushserver/config"
 	"github.com/piyushsharma67/codepushserver/models"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MySQLDB struct {
@@ -44,22 +46,29 @@ func (d *MySQLDB) Close() error {
 }
 
 func (d *MySQLDB) Migrate() error {
-	// Drop existing tables to ensure clean migration
-	if err := d.db.Migrator().DropTable(
-		&models.Organization{},
-		&models.OrganizationMember{},
-		&models.OrganizationInvitation{},
-	); err != nil {
-		return err
-	}
-
-	// Create tables with new schema
 	return d.db.AutoMigrate(
 		&models.User{},
 		&models.App{},
+		&models.Deployment{},
+		&models.Release{},
+		&models.StatusReport{},
+		&models.PackageDiff{},
+		&models.ReleaseMetric{},
+		&models.AccessKey{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserToken{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.LoginEvent{},
 		&models.Organization{},
 		&models.OrganizationMember{},
 		&models.OrganizationInvitation{},
+		&models.ServiceAccount{},
+		&models.ServiceAccountGrant{},
 	)
 }
 
@@ -88,6 +97,363 @@ func (d *MySQLDB) UpdateUser(user *models.User) error {
 	return d.db.Save(user).Error
 }
 
+// Access key methods
+func (d *MySQLDB) CreateAccessKey(key *models.AccessKey) error {
+	return d.db.Create(key).Error
+}
+
+func (d *MySQLDB) FindAccessKeyByID(id uint) (*models.AccessKey, error) {
+	var key models.AccessKey
+	if err := d.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (d *MySQLDB) FindAccessKeyByHash(keyHash string) (*models.AccessKey, error) {
+	var key models.AccessKey
+	if err := d.db.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (d *MySQLDB) FindAccessKeysByUserID(userID uint) ([]*models.AccessKey, error) {
+	var keys []*models.AccessKey
+	if err := d.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (d *MySQLDB) UpdateAccessKey(key *models.AccessKey) error {
+	return d.db.Save(key).Error
+}
+
+// TouchAccessKey records when a key was last used without a full update, so
+// concurrent requests do not overwrite a revocation
+func (d *MySQLDB) TouchAccessKey(id uint, usedAt time.Time) error {
+	return d.db.Model(&models.AccessKey{}).Where("id = ?", id).UpdateColumn("last_used_at", usedAt).Error
+}
+
+// Token methods
+func (d *MySQLDB) CreateRefreshToken(token *models.RefreshToken) error {
+	return d.db.Create(token).Error
+}
+
+func (d *MySQLDB) FindRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := d.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed marks a token as used unless it already was, reporting
+// whether this call did so. Only one of several concurrent refreshes with the
+// same token can succeed.
+func (d *MySQLDB) MarkRefreshTokenUsed(id uint, usedAt time.Time) (bool, error) {
+	result := d.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", usedAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (d *MySQLDB) RevokeRefreshTokenFamily(familyID string, revokedAt time.Time) error {
+	return d.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		UpdateColumn("revoked_at", revokedAt).Error
+}
+
+func (d *MySQLDB) RevokeUserRefreshTokens(userID uint, revokedAt time.Time) error {
+	return d.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", revokedAt).Error
+}
+
+// RevokeToken adds an access token to the revocation list, dropping entries
+// for tokens that have expired anyway
+func (d *MySQLDB) RevokeToken(revoked *models.RevokedToken) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(revoked).Error
+	})
+}
+
+func (d *MySQLDB) IsTokenRevoked(tokenID string) (bool, error) {
+	var count int64
+	if err := d.db.Model(&models.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Session methods
+func (d *MySQLDB) CreateSession(session *models.Session) error {
+	return d.db.Create(session).Error
+}
+
+func (d *MySQLDB) FindSessionByID(id string) (*models.Session, error) {
+	var session models.Session
+	if err := d.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindActiveSessionsByUserID returns the sessions of a user that are neither
+// revoked nor expired, most recently used first
+func (d *MySQLDB) FindActiveSessionsByUserID(userID uint) ([]*models.Session, error) {
+	var sessions []*models.Session
+	if err := d.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// TouchSession records the latest use of a session without a full update, so
+// concurrent requests do not overwrite a revocation
+func (d *MySQLDB) TouchSession(id, ip string, usedAt time.Time) error {
+	return d.db.Model(&models.Session{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"ip": ip, "last_used_at": usedAt}).Error
+}
+
+// ExtendSession moves the expiry of a session when its refresh token is used
+func (d *MySQLDB) ExtendSession(id, ip string, usedAt, expiresAt time.Time) error {
+	return d.db.Model(&models.Session{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"ip": ip, "last_used_at": usedAt, "expires_at": expiresAt}).Error
+}
+
+// RevokeSession revokes a session along with its refresh tokens
+func (d *MySQLDB) RevokeSession(id string, revokedAt time.Time) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			UpdateColumn("revoked_at", revokedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", id).
+			UpdateColumn("revoked_at", revokedAt).Error
+	})
+}
+
+func (d *MySQLDB) RevokeUserSessions(userID uint, revokedAt time.Time) error {
+	return d.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", revokedAt).Error
+}
+
+// CreateUserToken stores a new emailed token, replacing the user's earlier
+// tokens for the same purpose so only the latest link works, and dropping
+// expired tokens
+func (d *MySQLDB) CreateUserToken(token *models.UserToken) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ? OR (user_id = ? AND purpose = ?)", time.Now(), token.UserID, token.Purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (d *MySQLDB) FindUserTokenByHash(tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := d.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUserTokenUsed marks a token as used unless it already was, reporting
+// whether this call did so
+func (d *MySQLDB) MarkUserTokenUsed(id uint, usedAt time.Time) (bool, error) {
+	result := d.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", usedAt)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Identity methods
+func (d *MySQLDB) CreateUserIdentity(identity *models.UserIdentity) error {
+	return d.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity creates a user signing in through a provider for the
+// first time along with the link to the provider's account
+func (d *MySQLDB) CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (d *MySQLDB) FindUserIdentity(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := d.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// CreateOIDCLoginState stores a new sign in attempt, dropping expired ones
+func (d *MySQLDB) CreateOIDCLoginState(state *models.OIDCLoginState) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(state).Error
+	})
+}
+
+// ConsumeOIDCLoginState finds and deletes a sign in attempt, so each one can
+// only complete once
+func (d *MySQLDB) ConsumeOIDCLoginState(state string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ?", state).First(&loginState).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&loginState)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &loginState, nil
+}
+
+// Two-factor methods
+
+// UpdateTOTPLastStep records the time step of an accepted TOTP code unless a
+// code of the same or a later step was already accepted, reporting whether
+// this call did so
+func (d *MySQLDB) UpdateTOTPLastStep(userID uint, step int64) (bool, error) {
+	result := d.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (d *MySQLDB) ReplaceRecoveryCodes(userID uint, codes []*models.RecoveryCode) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (d *MySQLDB) FindRecoveryCodesByUserID(userID uint) ([]*models.RecoveryCode, error) {
+	var codes []*models.RecoveryCode
+	if err := d.db.Where("user_id = ?", userID).Find(&codes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used, reporting
+// whether there was one
+func (d *MySQLDB) UseRecoveryCode(userID uint, codeHash string, usedAt time.Time) (bool, error) {
+	result := d.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		UpdateColumn("used_at", usedAt)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateLoginChallenge stores a login waiting for its second factor,
+// dropping expired ones
+func (d *MySQLDB) CreateLoginChallenge(challenge *models.LoginChallenge) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.LoginChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(challenge).Error
+	})
+}
+
+func (d *MySQLDB) FindLoginChallengeByHash(tokenHash string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	if err := d.db.Where("token_hash = ?", tokenHash).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (d *MySQLDB) IncrementLoginChallengeAttempts(id uint) error {
+	return d.db.Model(&models.LoginChallenge{}).Where("id = ?", id).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// DeleteLoginChallenge deletes a challenge, reporting whether this call did
+// so, so each challenge can only complete once
+func (d *MySQLDB) DeleteLoginChallenge(id uint) (bool, error) {
+	result := d.db.Delete(&models.LoginChallenge{}, id)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Login event methods
+func (d *MySQLDB) CreateLoginEvent(event *models.LoginEvent) error {
+	return d.db.Create(event).Error
+}
+
+// FindLoginEventsByEmail returns the latest sign in attempts for an email
+// with one of the given results since a time, newest first
+func (d *MySQLDB) FindLoginEventsByEmail(email string, results []string, since time.Time, limit int) ([]*models.LoginEvent, error) {
+	var events []*models.LoginEvent
+	if err := d.db.Where("email = ? AND result IN ? AND created_at >= ?", email, results, since).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// FindLoginEventsByIP returns the latest sign in attempts from an IP address
+// with one of the given results since a time, newest first
+func (d *MySQLDB) FindLoginEventsByIP(ip string, results []string, since time.Time, limit int) ([]*models.LoginEvent, error) {
+	var events []*models.LoginEvent
+	if err := d.db.Where("ip = ? AND result IN ? AND created_at >= ?", ip, results, since).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// UpdateLoginEventResult sets the result of a sign in attempt once its
+// credentials have been checked
+func (d *MySQLDB) UpdateLoginEventResult(id uint, result string) error {
+	return d.db.Model(&models.LoginEvent{}).Where("id = ?", id).UpdateColumn("result", result).Error
+}
+
+func (d *MySQLDB) FindLoginEventsByUserID(userID uint, limit int) ([]*models.LoginEvent, error) {
+	var events []*models.LoginEvent
+	if err := d.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
 // App methods
 func (d *MySQLDB) CreateApp(app *models.App) error {
 	return d.db.Create(app).Error
@@ -114,7 +480,239 @@ func (d *MySQLDB) UpdateApp(app *models.App) error {
 }
 
 func (d *MySQLDB) DeleteApp(id string) error {
-	return d.db.Delete(&models.App{}, "id = ?", id).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		deploymentIDs := tx.Model(&models.Deployment{}).Select("id").Where("app_id = ?", id)
+		if err := tx.Delete(&models.StatusReport{}, "deployment_id IN (?)", deploymentIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ReleaseMetric{}, "deployment_id IN (?)", deploymentIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Release{}, "deployment_id IN (?)", deploymentIDs).Error; err != nil {
+			return err
+		}
+		// Packages are shared by content, so only diffs no remaining release
+		// can serve are removed
+		if err := tx.Where("to_blob_path NOT IN (?) OR from_package_hash NOT IN (?)",
+			tx.Model(&models.Release{}).Select("blob_path"), tx.Model(&models.Release{}).Select("package_hash")).
+			Delete(&models.PackageDiff{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ServiceAccountGrant{}, "app_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Deployment{}, "app_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.App{}, "id = ?", id).Error
+	})
+}
+
+// Deployment methods
+func (d *MySQLDB) CreateDeployment(deployment *models.Deployment) error {
+	return d.db.Create(deployment).Error
+}
+
+func (d *MySQLDB) FindDeploymentByName(appID, name string) (*models.Deployment, error) {
+	var deployment models.Deployment
+	if err := d.db.Where("app_id = ? AND name = ?", appID, name).First(&deployment).Error; err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+func (d *MySQLDB) FindDeploymentByKey(key string) (*models.Deployment, error) {
+	var deployment models.Deployment
+	if err := d.db.Where(map[string]interface{}{"key": key}).First(&deployment).Error; err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+func (d *MySQLDB) FindDeploymentsByAppID(appID string) ([]*models.Deployment, error) {
+	var deployments []*models.Deployment
+	if err := d.db.Where("app_id = ?", appID).Order("id").Find(&deployments).Error; err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+func (d *MySQLDB) UpdateDeployment(deployment *models.Deployment) error {
+	// The label counter is only ever advanced by CreateRelease
+	return d.db.Omit("LastLabelNumber").Save(deployment).Error
+}
+
+func (d *MySQLDB) DeleteDeployment(id uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var deployment models.Deployment
+		if err := tx.First(&deployment, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.StatusReport{}, "deployment_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ReleaseMetric{}, "deployment_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Release{}, "deployment_id = ?", id).Error; err != nil {
+			return err
+		}
+		// Packages are shared by content, so only diffs no remaining release
+		// can serve are removed
+		if err := tx.Where("to_blob_path NOT IN (?) OR from_package_hash NOT IN (?)",
+			tx.Model(&models.Release{}).Select("blob_path"), tx.Model(&models.Release{}).Select("package_hash")).
+			Delete(&models.PackageDiff{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ServiceAccountGrant{}, "app_id = ? AND deployment_name = ?", deployment.AppID, deployment.Name).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Deployment{}, id).Error
+	})
+}
+
+// Release methods
+
+// CreateRelease assigns the next label of the deployment to the release and
+// stores it. The deployment row is locked for the duration of the transaction
+// so concurrent uploads never receive the same label.
+func (d *MySQLDB) CreateRelease(release *models.Release) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var deployment models.Deployment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deployment, release.DeploymentID).Error; err != nil {
+			return err
+		}
+
+		deployment.LastLabelNumber++
+		if err := tx.Model(&deployment).Update("last_label_number", deployment.LastLabelNumber).Error; err != nil {
+			return err
+		}
+
+		release.Label = fmt.Sprintf("v%d", deployment.LastLabelNumber)
+		return tx.Create(release).Error
+	})
+}
+
+func (d *MySQLDB) FindLatestRelease(deploymentID uint) (*models.Release, error) {
+	var release models.Release
+	if err := d.db.Where("deployment_id = ?", deploymentID).Order("id DESC").First(&release).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// FindReleasesByDeploymentID returns all releases of a deployment, newest first
+func (d *MySQLDB) FindReleasesByDeploymentID(deploymentID uint) ([]*models.Release, error) {
+	var releases []*models.Release
+	if err := d.db.Where("deployment_id = ?", deploymentID).Order("id DESC").Find(&releases).Error; err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// FindReleasesPage returns one page of a deployment's releases, newest first,
+// along with the total number of releases
+func (d *MySQLDB) FindReleasesPage(deploymentID uint, offset, limit int) ([]*models.Release, int64, error) {
+	var total int64
+	if err := d.db.Model(&models.Release{}).Where("deployment_id = ?", deploymentID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var releases []*models.Release
+	if err := d.db.Where("deployment_id = ?", deploymentID).Order("id DESC").Offset(offset).Limit(limit).Find(&releases).Error; err != nil {
+		return nil, 0, err
+	}
+	return releases, total, nil
+}
+
+func (d *MySQLDB) FindReleaseByLabel(deploymentID uint, label string) (*models.Release, error) {
+	var release models.Release
+	if err := d.db.Where("deployment_id = ? AND label = ?", deploymentID, label).First(&release).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// FindReleaseByPackageHash returns a release of an app with the given package
+func (d *MySQLDB) FindReleaseByPackageHash(appID, packageHash string) (*models.Release, error) {
+	var release models.Release
+	if err := d.db.Joins("JOIN deployments ON deployments.id = releases.deployment_id").
+		Where("deployments.app_id = ? AND releases.package_hash = ?", appID, packageHash).
+		First(&release).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// FindReleaseByBlobPath returns a release whose package is stored under a key
+func (d *MySQLDB) FindReleaseByBlobPath(blobPath string) (*models.Release, error) {
+	var release models.Release
+	if err := d.db.Where("blob_path = ?", blobPath).First(&release).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+func (d *MySQLDB) UpdateRelease(release *models.Release) error {
+	return d.db.Save(release).Error
+}
+
+// Package diff methods
+func (d *MySQLDB) CreatePackageDiff(diff *models.PackageDiff) error {
+	return d.db.Create(diff).Error
+}
+
+func (d *MySQLDB) FindPackageDiff(fromPackageHash, toBlobPath string) (*models.PackageDiff, error) {
+	var diff models.PackageDiff
+	if err := d.db.Where("from_package_hash = ? AND to_blob_path = ?", fromPackageHash, toBlobPath).First(&diff).Error; err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// FindPackageDiffByBlobPath returns the diff stored under a key
+func (d *MySQLDB) FindPackageDiffByBlobPath(blobPath string) (*models.PackageDiff, error) {
+	var diff models.PackageDiff
+	if err := d.db.Where("blob_path = ?", blobPath).First(&diff).Error; err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// Status report methods
+func (d *MySQLDB) CreateStatusReport(report *models.StatusReport) error {
+	return d.db.Create(report).Error
+}
+
+// Release metric methods
+
+// IncrementReleaseMetric atomically adds delta to one counter of a label,
+// creating the row on first use. Counters never drop below zero.
+func (d *MySQLDB) IncrementReleaseMetric(deploymentID uint, label, metric string, delta int) error {
+	switch metric {
+	case models.MetricActive, models.MetricDownloaded, models.MetricInstalled, models.MetricFailed, models.MetricRollbacks:
+	default:
+		return fmt.Errorf("unknown metric %q", metric)
+	}
+
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ReleaseMetric{
+		DeploymentID: deploymentID,
+		Label:        label,
+	}).Error; err != nil {
+		return err
+	}
+
+	return d.db.Model(&models.ReleaseMetric{}).
+		Where("deployment_id = ? AND label = ?", deploymentID, label).
+		UpdateColumn(metric, gorm.Expr("CASE WHEN "+metric+" + ? < 0 THEN 0 ELSE "+metric+" + ? END", delta, delta)).Error
+}
+
+func (d *MySQLDB) FindReleaseMetrics(deploymentID uint) ([]*models.ReleaseMetric, error) {
+	var metrics []*models.ReleaseMetric
+	if err := d.db.Where("deployment_id = ?", deploymentID).Order("id").Find(&metrics).Error; err != nil {
+		return nil, err
+	}
+	return metrics, nil
 }
 
 // Organization methods
@@ -140,6 +738,10 @@ func (d *MySQLDB) FindOrganizationsByUserID(userID uint) ([]*models.Organization
 	return orgs, nil
 }
 
+func (d *MySQLDB) UpdateOrganization(org *models.Organization) error {
+	return d.db.Save(org).Error
+}
+
 func (d *MySQLDB) DeleteOrganization(id uuid.UUID) error {
 	return d.db.Delete(&models.Organization{}, "id = ?", id).Error
 }
@@ -166,9 +768,9 @@ func (d *MySQLDB) CreateOrganizationInvitation(invitation *models.OrganizationIn
 	return d.db.Create(invitation).Error
 }
 
-func (d *MySQLDB) FindOrganizationInvitationByID(id uuid.UUID) (*models.OrganizationInvitation, error) {
+func (d *MySQLDB) FindOrganizationInvitationByID(id uint) (*models.OrganizationInvitation, error) {
 	var invitation models.OrganizationInvitation
-	if err := d.db.First(&invitation, "id = ?", id).Error; err != nil {
+	if err := d.db.First(&invitation, id).Error; err != nil {
 		return nil, err
 	}
 	return &invitation, nil
@@ -176,7 +778,7 @@ func (d *MySQLDB) FindOrganizationInvitationByID(id uuid.UUID) (*models.Organiza
 
 func (d *MySQLDB) FindPendingInvitationsByEmail(email string) ([]*models.OrganizationInvitation, error) {
 	var invitations []*models.OrganizationInvitation
-	if err := d.db.Where("email = ? AND status = ?", email, "pending").Find(&invitations).Error; err != nil {
+	if err := d.db.Where("email = ? AND status = ?", email, models.InvitationStatusPending).Find(&invitations).Error; err != nil {
 		return nil, err
 	}
 	return invitations, nil
@@ -184,4 +786,125 @@ func (d *MySQLDB) FindPendingInvitationsByEmail(email string) ([]*models.Organiz
 
 func (d *MySQLDB) UpdateOrganizationInvitation(invitation *models.OrganizationInvitation) error {
 	return d.db.Save(invitation).Error
-} 
\ No newline at end of file
+}
+
+// AcceptOrganizationInvitation marks a pending invitation accepted and adds
+// the invitee as a member in one transaction. It returns false if the
+// invitation is no longer pending.
+func (d *MySQLDB) AcceptOrganizationInvitation(id uint, member *models.OrganizationMember) (bool, error) {
+	accepted := false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrganizationInvitation{}).
+			Where("id = ? AND status = ?", id, models.InvitationStatusPending).
+			Update("status", models.InvitationStatusAccepted)
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		accepted = true
+		return nil
+	})
+	return accepted, err
+}
+
+// DeclineOrganizationInvitation marks a pending invitation declined. It
+// returns false if the invitation is no longer pending.
+func (d *MySQLDB) DeclineOrganizationInvitation(id uint) (bool, error) {
+	result := d.db.Model(&models.OrganizationInvitation{}).
+		Where("id = ? AND status = ?", id, models.InvitationStatusPending).
+		Update("status", models.InvitationStatusDeclined)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Service account methods
+
+// CreateServiceAccount creates a service account along with the user backing
+// it and its organization membership
+func (d *MySQLDB) CreateServiceAccount(account *models.ServiceAccount, user *models.User, member *models.OrganizationMember) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		account.UserID = user.ID
+		member.UserID = user.ID
+		if err := tx.Create(account).Error; err != nil {
+			return err
+		}
+		return tx.Create(member).Error
+	})
+}
+
+func (d *MySQLDB) FindServiceAccountByID(id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := d.db.First(&account, id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (d *MySQLDB) FindServiceAccountByUserID(userID uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := d.db.Where("user_id = ?", userID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindServiceAccountsByOrganizationID returns the service accounts of an
+// organization that have not been deleted
+func (d *MySQLDB) FindServiceAccountsByOrganizationID(orgID uuid.UUID) ([]*models.ServiceAccount, error) {
+	var accounts []*models.ServiceAccount
+	if err := d.db.Where("organization_id = ? AND deleted_at IS NULL", orgID).
+		Order("created_at").
+		Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// DeleteServiceAccount marks a service account deleted, revoking its access
+// keys and removing its organization membership and grants. The account and
+// its user are kept so releases it made can still be attributed.
+func (d *MySQLDB) DeleteServiceAccount(account *models.ServiceAccount, deletedAt time.Time) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(account).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AccessKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", account.UserID).
+			UpdateColumn("revoked_at", deletedAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ? AND user_id = ?", account.OrganizationID, account.UserID).
+			Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("service_account_id = ?", account.ID).Delete(&models.ServiceAccountGrant{}).Error
+	})
+}
+
+func (d *MySQLDB) CreateServiceAccountGrant(grant *models.ServiceAccountGrant) error {
+	return d.db.Create(grant).Error
+}
+
+func (d *MySQLDB) FindServiceAccountGrantByID(id uint) (*models.ServiceAccountGrant, error) {
+	var grant models.ServiceAccountGrant
+	if err := d.db.First(&grant, id).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (d *MySQLDB) FindServiceAccountGrants(serviceAccountID uint) ([]*models.ServiceAccountGrant, error) {
+	var grants []*models.ServiceAccountGrant
+	if err := d.db.Where("service_account_id = ?", serviceAccountID).Order("id").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (d *MySQLDB) DeleteServiceAccountGrant(id uint) error {
+	return d.db.Delete(&models.ServiceAccountGrant{}, id).Error
+}
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type AccessKeyHandler struct {
+	accessKeyService *v1.AccessKeyService
+}
+
+func NewAccessKeyHandler(db database.Database) *AccessKeyHandler {
+	return &AccessKeyHandler{
+		accessKeyService: v1.NewAccessKeyService(db),
+	}
+}
+
+type CreateAccessKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=128"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func accessKeyResponse(key *models.AccessKey) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scope":        key.Scope,
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
+
+func (h *AccessKeyHandler) CreateAccessKey(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateAccessKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := h.accessKeyService.CreateAccessKey(userID, req.Name, req.Scope, req.ExpiresAt)
+	if err != nil {
+		if err == v1.ErrInvalidScope || err == v1.ErrInvalidExpiry {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access key"})
+		return
+	}
+
+	// The key is only ever returned here
+	response := accessKeyResponse(key)
+	response["key"] = secret
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Access key created successfully",
+		"access_key": response,
+	})
+}
+
+func (h *AccessKeyHandler) GetAccessKeys(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keys, err := h.accessKeyService.GetAccessKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access keys"})
+		return
+	}
+
+	responseKeys := []gin.H{}
+	for _, key := range keys {
+		responseKeys = append(responseKeys, accessKeyResponse(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_keys": responseKeys,
+	})
+}
+
+func (h *AccessKeyHandler) RevokeAccessKey(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access key ID"})
+		return
+	}
+
+	if err := h.accessKeyService.RevokeAccessKey(userID, uint(keyID)); err != nil {
+		switch err {
+		case utils.ErrAccessDenied:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		case utils.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Access key not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Access key revoked successfully",
+	})
+}
@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+// AcquisitionHandler serves the public API used by the react-native-code-push
+// SDK. Every endpoint is exposed in the legacy camelCase shape and the
+// snake_case shape of the App Center v0.1 API.
+type AcquisitionHandler struct {
+	acquisitionService *v1.AcquisitionService
+}
+
+func NewAcquisitionHandler(db database.Database, store storage.BlobStore, cfg *config.Config) *AcquisitionHandler {
+	return &AcquisitionHandler{
+		acquisitionService: v1.NewAcquisitionService(db, store, cfg),
+	}
+}
+
+type LegacyUpdateCheckRequest struct {
+	DeploymentKey  string `form:"deploymentKey" binding:"required"`
+	AppVersion     string `form:"appVersion" binding:"required"`
+	PackageHash    string `form:"packageHash"`
+	ClientUniqueID string `form:"clientUniqueId"`
+	IsCompanion    bool   `form:"isCompanion"`
+}
+
+type UpdateCheckRequest struct {
+	DeploymentKey  string `form:"deployment_key" binding:"required"`
+	AppVersion     string `form:"app_version" binding:"required"`
+	PackageHash    string `form:"package_hash"`
+	ClientUniqueID string `form:"client_unique_id"`
+	IsCompanion    bool   `form:"is_companion"`
+}
+
+func respondAcquisitionError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+		return
+	case v1.ErrInvalidAppVersion:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for updates"})
+}
+
+// LegacyUpdateCheck handles GET /updateCheck
+func (h *AcquisitionHandler) LegacyUpdateCheck(c *gin.Context) {
+	var req LegacyUpdateCheckRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := h.acquisitionService.UpdateCheck(v1.UpdateCheckParams{
+		DeploymentKey:  req.DeploymentKey,
+		AppVersion:     req.AppVersion,
+		PackageHash:    req.PackageHash,
+		ClientUniqueID: req.ClientUniqueID,
+		IsCompanion:    req.IsCompanion,
+	})
+	if err != nil {
+		respondAcquisitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"updateInfo": gin.H{
+			"isAvailable":            info.IsAvailable,
+			"isMandatory":            info.IsMandatory,
+			"appVersion":             info.AppVersion,
+			"packageHash":            info.PackageHash,
+			"label":                  info.Label,
+			"packageSize":            info.PackageSize,
+			"description":            info.Description,
+			"downloadURL":            info.DownloadURL,
+			"updateAppVersion":       info.UpdateAppVersion,
+			"shouldRunBinaryVersion": info.ShouldRunBinaryVersion,
+		},
+	})
+}
+
+// UpdateCheck handles GET /v0.1/public/codepush/update_check
+func (h *AcquisitionHandler) UpdateCheck(c *gin.Context) {
+	var req UpdateCheckRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := h.acquisitionService.UpdateCheck(v1.UpdateCheckParams{
+		DeploymentKey:  req.DeploymentKey,
+		AppVersion:     req.AppVersion,
+		PackageHash:    req.PackageHash,
+		ClientUniqueID: req.ClientUniqueID,
+		IsCompanion:    req.IsCompanion,
+	})
+	if err != nil {
+		respondAcquisitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"update_info": gin.H{
+			"is_available":              info.IsAvailable,
+			"is_mandatory":              info.IsMandatory,
+			"target_binary_range":       info.AppVersion,
+			"package_hash":              info.PackageHash,
+			"label":                     info.Label,
+			"package_size":              info.PackageSize,
+			"description":               info.Description,
+			"download_url":              info.DownloadURL,
+			"update_app_version":        info.UpdateAppVersion,
+			"should_run_binary_version": info.ShouldRunBinaryVersion,
+		},
+	})
+}
+
+type LegacyReportDeployRequest struct {
+	DeploymentKey             string `json:"deploymentKey" binding:"required"`
+	Label                     string `json:"label"`
+	AppVersion                string `json:"appVersion" binding:"required"`
+	PreviousDeploymentKey     string `json:"previousDeploymentKey"`
+	PreviousLabelOrAppVersion string `json:"previousLabelOrAppVersion"`
+	Status                    string `json:"status"`
+	ClientUniqueID            string `json:"clientUniqueId"`
+}
+
+type ReportDeployRequest struct {
+	DeploymentKey             string `json:"deployment_key" binding:"required"`
+	Label                     string `json:"label"`
+	AppVersion                string `json:"app_version" binding:"required"`
+	PreviousDeploymentKey     string `json:"previous_deployment_key"`
+	PreviousLabelOrAppVersion string `json:"previous_label_or_app_version"`
+	Status                    string `json:"status"`
+	ClientUniqueID            string `json:"client_unique_id"`
+}
+
+type LegacyReportDownloadRequest struct {
+	DeploymentKey  string `json:"deploymentKey" binding:"required"`
+	Label          string `json:"label" binding:"required"`
+	ClientUniqueID string `json:"clientUniqueId"`
+}
+
+type ReportDownloadRequest struct {
+	DeploymentKey  string `json:"deployment_key" binding:"required"`
+	Label          string `json:"label" binding:"required"`
+	ClientUniqueID string `json:"client_unique_id"`
+}
+
+func respondReportError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment or release not found"})
+	case v1.ErrInvalidStatus:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record status report"})
+	}
+}
+
+func (h *AcquisitionHandler) reportDeploy(c *gin.Context, params v1.DeployReportParams) {
+	if err := h.acquisitionService.ReportDeploy(params); err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status reported"})
+}
+
+func (h *AcquisitionHandler) reportDownload(c *gin.Context, params v1.DownloadReportParams) {
+	if err := h.acquisitionService.ReportDownload(params); err != nil {
+		respondReportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status reported"})
+}
+
+// LegacyReportDeploy handles POST /reportStatus/deploy
+func (h *AcquisitionHandler) LegacyReportDeploy(c *gin.Context) {
+	var req LegacyReportDeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reportDeploy(c, v1.DeployReportParams(req))
+}
+
+// ReportDeploy handles POST /v0.1/public/codepush/report_status/deploy
+func (h *AcquisitionHandler) ReportDeploy(c *gin.Context) {
+	var req ReportDeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reportDeploy(c, v1.DeployReportParams(req))
+}
+
+// LegacyReportDownload handles POST /reportStatus/download
+func (h *AcquisitionHandler) LegacyReportDownload(c *gin.Context) {
+	var req LegacyReportDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reportDownload(c, v1.DownloadReportParams(req))
+}
+
+// ReportDownload handles POST /v0.1/public/codepush/report_status/download
+func (h *AcquisitionHandler) ReportDownload(c *gin.Context) {
+	var req ReportDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.reportDownload(c, v1.DownloadReportParams(req))
+}
@@ -2,25 +2,42 @@ package v1
 
 import (
 	"crypto/rand"
+	"io"
+	"log"
+	"math"
 	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/config"
 	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/mailer"
 	"github.com/piyushsharma67/codepushserver/models"
 	"github.com/piyushsharma67/codepushserver/services"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	db         database.Database
-	jwtService *services.JWTService
+	db               database.Database
+	jwtService       *services.JWTService
+	tokenService     *v1.TokenService
+	accountService   *v1.AccountService
+	twoFactorService *v1.TwoFactorService
+	securityService  *v1.SecurityService
 }
 
-func NewAuthHandler(db database.Database) *AuthHandler {
+func NewAuthHandler(db database.Database, jwtService *services.JWTService, mail mailer.Mailer, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		db:         db,
-		jwtService: services.NewJWTService(),
+		db:               db,
+		jwtService:       jwtService,
+		tokenService:     v1.NewTokenService(db, jwtService),
+		accountService:   v1.NewAccountService(db, mail, jwtService, cfg),
+		twoFactorService: v1.NewTwoFactorService(db, jwtService, cfg),
+		securityService:  v1.NewSecurityService(db),
 	}
 }
 
@@ -37,6 +54,33 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP or recovery code
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"` // log out every session of the user
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -72,24 +116,21 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := h.jwtService.GenerateToken(user.ID, user.Email)
+	// The account is usable before the email is verified, so a mail failure
+	// does not fail registration; the user can ask for another email
+	if err := h.accountService.SendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	// Generate access and refresh tokens
+	client := v1.ClientInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	tokens, err := h.tokenService.IssueTokens(user, client)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token":     token,
-		"expires_at": expiresAt,
-		"user": gin.H{
-			"id":           user.ID,
-			"username":     user.Username,
-			"email":        user.Email,
-			"company_name": user.CompanyName,
-			"created_at":   user.CreatedAt,
-		},
-	})
+	c.JSON(http.StatusCreated, loginResponse(tokens, user))
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -99,39 +140,287 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Find user
-	user, err := h.db.FindUserByEmail(req.Email)
+	ip, userAgent := c.ClientIP(), c.Request.UserAgent()
+
+	// Find user, nil if no account has the email
+	user, _ := h.db.FindUserByEmail(req.Email)
+
+	// Record the attempt before checking it, so parallel attempts count
+	// against each other, and refuse it after too many failures for the
+	// account or IP
+	attempt, err := h.securityService.StartLogin(user, req.Email, ip, userAgent, models.LoginMethodPassword)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+	if retryAfter, err := h.securityService.CheckLogin(attempt); err != nil {
+		if err == v1.ErrTooManyAttempts {
+			h.securityService.FinishLogin(attempt, models.LoginResultBlocked)
+			tooManyAttempts(c, retryAfter)
+			return
+		}
+		h.securityService.FinishLogin(attempt, models.LoginResultError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+
+	if user == nil {
+		h.securityService.FinishLogin(attempt, models.LoginResultFailure)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.securityService.FinishLogin(attempt, models.LoginResultFailure)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	token, expiresAt, err := h.jwtService.GenerateToken(user.ID, user.Email)
+	// Generate tokens, or a challenge for users with two-factor authentication
+	result, err := h.twoFactorService.BeginLogin(user, v1.ClientInfo{IP: ip, UserAgent: userAgent})
 	if err != nil {
+		h.securityService.FinishLogin(attempt, models.LoginResultError)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	if result.Tokens == nil {
+		h.securityService.FinishLogin(attempt, models.LoginResultTwoFactorRequired)
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required":  true,
+			"challenge_token":      result.ChallengeToken,
+			"challenge_expires_at": result.ChallengeExpiresAt,
+		})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":     token,
-		"expires_at": expiresAt,
+	h.securityService.FinishLogin(attempt, models.LoginResultSuccess)
+	c.JSON(http.StatusOK, loginResponse(result.Tokens, user))
+}
+
+// LoginTwoFactor completes a login challenge with a code from the user's
+// authenticator app or a recovery code
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.twoFactorService.ChallengeUser(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Wrong codes count towards the same limits as wrong passwords
+	ip, userAgent := c.ClientIP(), c.Request.UserAgent()
+	attempt, err := h.securityService.StartLogin(user, user.Email, ip, userAgent, models.LoginMethodTwoFactor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+	if retryAfter, err := h.securityService.CheckLogin(attempt); err != nil {
+		if err == v1.ErrTooManyAttempts {
+			h.securityService.FinishLogin(attempt, models.LoginResultBlocked)
+			tooManyAttempts(c, retryAfter)
+			return
+		}
+		h.securityService.FinishLogin(attempt, models.LoginResultError)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+
+	tokens, _, err := h.twoFactorService.CompleteLogin(req.ChallengeToken, req.Code, v1.ClientInfo{IP: ip, UserAgent: userAgent})
+	if err != nil {
+		switch err {
+		case v1.ErrInvalidTwoFactorCode, v1.ErrInvalidChallenge:
+			h.securityService.FinishLogin(attempt, models.LoginResultFailure)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			h.securityService.FinishLogin(attempt, models.LoginResultError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		}
+		return
+	}
+
+	h.securityService.FinishLogin(attempt, models.LoginResultSuccess)
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// tooManyAttempts responds to a sign in attempt refused after too many
+// failures
+func tooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed sign in attempts, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// loginResponse is the response of a completed login or registration
+func loginResponse(tokens *v1.TokenPair, user *models.User) gin.H {
+	return gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
 		"user": gin.H{
-			"id":           user.ID,
-			"username":     user.Username,
-			"email":        user.Email,
-			"company_name": user.CompanyName,
-			"created_at":   user.CreatedAt,
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"company_name":   user.CompanyName,
+			"created_at":     user.CreatedAt,
 		},
+	}
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(req.RefreshToken, c.ClientIP())
+	if err != nil {
+		switch err {
+		case v1.ErrInvalidRefreshToken, v1.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
 	})
 }
 
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The body is optional
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.All {
+		if !v1.ScopeAllows(c.GetString("scope"), models.ScopeAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access key does not have the admin scope"})
+			return
+		}
+		if err := h.tokenService.LogoutAll(userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+		return
+	}
+
+	// Requests authenticated with an access key have no token to revoke
+	claims := &services.TokenClaims{UserID: userID}
+	if value, ok := c.Get("token_claims"); ok {
+		claims = value.(*services.TokenClaims)
+	}
+	if err := h.tokenService.Logout(claims, req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.accountService.VerifyEmail(req.Token); err != nil {
+		if err == v1.ErrInvalidUserToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.accountService.ResendVerificationEmail(userID); err != nil {
+		switch err {
+		case v1.ErrEmailAlreadyVerified:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case utils.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The same response whether or not the account exists or the email was
+	// sent
+	h.accountService.ForgotPassword(req.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+}
+
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(req.Token, req.Password); err != nil {
+		if err == v1.ErrInvalidUserToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in again"})
+}
+
+// JWKS publishes the keys tokens are verified with
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
+
 func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	result := make([]byte, length)
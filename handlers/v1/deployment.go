@@ -0,0 +1,168 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type DeploymentHandler struct {
+	deploymentService *v1.DeploymentService
+}
+
+func NewDeploymentHandler(db database.Database) *DeploymentHandler {
+	return &DeploymentHandler{
+		deploymentService: v1.NewDeploymentService(db),
+	}
+}
+
+type CreateDeploymentRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateDeploymentRequest struct {
+	Name             string  `json:"name"`
+	RegenerateKey    bool    `json:"regenerate_key"`
+	SigningPublicKey *string `json:"signing_public_key"`
+}
+
+func deploymentResponse(deployment *models.Deployment) gin.H {
+	return gin.H{
+		"id":                 deployment.ID,
+		"app_id":             deployment.AppID,
+		"name":               deployment.Name,
+		"key":                deployment.Key,
+		"signing_public_key": deployment.SigningPublicKey,
+		"created_at":         deployment.CreatedAt,
+	}
+}
+
+// respondDeploymentError maps service errors to HTTP responses
+func respondDeploymentError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case utils.ErrAccessDenied:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+	case utils.ErrAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Deployment already exists"})
+	case v1.ErrInvalidSigningKey:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func (h *DeploymentHandler) CreateDeployment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateDeploymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployment, err := h.deploymentService.CreateDeployment(userID, c.Param("id"), req.Name)
+	if err != nil {
+		respondDeploymentError(c, err, "Failed to create deployment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Deployment created successfully",
+		"deployment": deploymentResponse(deployment),
+	})
+}
+
+func (h *DeploymentHandler) GetDeployments(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	deployments, err := h.deploymentService.GetDeployments(userID, c.Param("id"))
+	if err != nil {
+		if err == utils.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+			return
+		}
+		respondDeploymentError(c, err, "Failed to fetch deployments")
+		return
+	}
+
+	responseDeployments := []gin.H{}
+	for _, deployment := range deployments {
+		responseDeployments = append(responseDeployments, deploymentResponse(deployment))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deployments": responseDeployments,
+	})
+}
+
+func (h *DeploymentHandler) GetDeployment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	deployment, err := h.deploymentService.GetDeployment(userID, c.Param("id"), c.Param("name"))
+	if err != nil {
+		respondDeploymentError(c, err, "Failed to fetch deployment")
+		return
+	}
+
+	c.JSON(http.StatusOK, deploymentResponse(deployment))
+}
+
+func (h *DeploymentHandler) UpdateDeployment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdateDeploymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deployment, err := h.deploymentService.UpdateDeployment(userID, c.Param("id"), c.Param("name"), req.Name, req.RegenerateKey, req.SigningPublicKey)
+	if err != nil {
+		respondDeploymentError(c, err, "Failed to update deployment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Deployment updated successfully",
+		"deployment": deploymentResponse(deployment),
+	})
+}
+
+func (h *DeploymentHandler) DeleteDeployment(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.deploymentService.DeleteDeployment(userID, c.Param("id"), c.Param("name")); err != nil {
+		respondDeploymentError(c, err, "Failed to delete deployment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deployment deleted successfully",
+	})
+}
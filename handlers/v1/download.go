@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/storage"
+)
+
+// DownloadHandler serves update packages from blob stores that have no
+// download URLs of their own, such as the local filesystem
+type DownloadHandler struct {
+	db    database.Database
+	store storage.BlobStore
+}
+
+func NewDownloadHandler(db database.Database, store storage.BlobStore) *DownloadHandler {
+	return &DownloadHandler{db: db, store: store}
+}
+
+// packageHash returns the hash of the package stored under a key, or of the
+// package a stored diff produces
+func (h *DownloadHandler) packageHash(key string) (string, error) {
+	if release, err := h.db.FindReleaseByBlobPath(key); err == nil {
+		return release.PackageHash, nil
+	}
+	diff, err := h.db.FindPackageDiffByBlobPath(key)
+	if err != nil {
+		return "", err
+	}
+	return diff.ToPackageHash, nil
+}
+
+func (h *DownloadHandler) Download(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	// Only blobs of releases and their diffs are served
+	packageHash, err := h.packageHash(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return
+	}
+
+	info, err := h.store.Stat(key)
+	if err == storage.ErrNotFound || err == storage.ErrInvalidKey {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read package"})
+		return
+	}
+
+	blob, err := h.store.Get(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read package"})
+		return
+	}
+	defer blob.Close()
+
+	// Blobs never change once written, so clients and proxies may cache them
+	// indefinitely
+	c.Header("ETag", `"`+packageHash+`"`)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("Content-Type", "application/zip")
+
+	// ServeContent handles Range and conditional requests, letting clients
+	// resume interrupted downloads
+	if content, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, content)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, info.Size, "application/zip", blob, nil)
+}
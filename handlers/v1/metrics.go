@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+)
+
+type MetricsHandler struct {
+	metricsService *v1.MetricsService
+}
+
+func NewMetricsHandler(db database.Database) *MetricsHandler {
+	return &MetricsHandler{
+		metricsService: v1.NewMetricsService(db),
+	}
+}
+
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	metrics, err := h.metricsService.GetMetrics(userID, c.Param("id"), c.Param("name"))
+	if err != nil {
+		respondDeploymentError(c, err, "Failed to fetch metrics")
+		return
+	}
+
+	responseMetrics := gin.H{}
+	for label, metric := range metrics {
+		responseMetrics[label] = gin.H{
+			"active":     metric.Active,
+			"downloaded": metric.Downloaded,
+			"installed":  metric.Installed,
+			"failed":     metric.Failed,
+			"rollbacks":  metric.Rollbacks,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metrics": responseMetrics,
+	})
+}
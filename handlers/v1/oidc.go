@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+)
+
+type OIDCHandler struct {
+	oidcService     *v1.OIDCService
+	securityService *v1.SecurityService
+}
+
+func NewOIDCHandler(db database.Database, jwtService *services.JWTService, cfg *config.Config) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:     v1.NewOIDCService(db, jwtService, cfg),
+		securityService: v1.NewSecurityService(db),
+	}
+}
+
+// GetProviders lists the identity providers users can sign in with
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oidcService.Providers()})
+}
+
+// Login sends the user to the provider's sign in page. Clients that pass a
+// redirect_uri receive the tokens in its fragment once sign in completes.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.oidcService.StartLogin(c.Param("provider"), c.Query("redirect_uri"))
+	if err != nil {
+		switch {
+		case errors.Is(err, v1.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, v1.ErrRedirectNotAllowed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, v1.ErrProviderFailure):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign in"})
+		}
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes sign in when the provider redirects back
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	code := c.Query("code")
+	if c.Query("error") != "" {
+		// The user declined or the provider failed; the state is still
+		// consumed so it cannot be replayed
+		code = ""
+	}
+
+	ip, userAgent := c.ClientIP(), c.Request.UserAgent()
+	client := v1.ClientInfo{IP: ip, UserAgent: userAgent}
+	result, redirectURI, err := h.oidcService.CompleteLogin(c.Param("provider"), code, c.Query("state"), client)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to sign in"
+		switch {
+		case errors.Is(err, v1.ErrUnknownProvider):
+			status, message = http.StatusNotFound, err.Error()
+		case errors.Is(err, v1.ErrInvalidLoginState):
+			status, message = http.StatusBadRequest, err.Error()
+		case errors.Is(err, v1.ErrEmailNotVerified), errors.Is(err, v1.ErrAccountNotVerified):
+			status, message = http.StatusForbidden, err.Error()
+		case errors.Is(err, v1.ErrProviderFailure):
+			status, message = http.StatusBadGateway, v1.ErrProviderFailure.Error()
+		}
+
+		if redirectURI != "" {
+			c.Redirect(http.StatusFound, redirectURI+"#"+url.Values{"error": {message}}.Encode())
+			return
+		}
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	method := models.LoginMethodOIDC + ":" + c.Param("provider")
+
+	// Users with two-factor authentication complete the challenge at
+	// /auth/login/2fa, as after a password login
+	if result.Tokens == nil {
+		h.securityService.RecordLogin(result.User, result.User.Email, ip, userAgent, method, models.LoginResultTwoFactorRequired)
+		if redirectURI != "" {
+			fragment := url.Values{
+				"two_factor_required":  {"true"},
+				"challenge_token":      {result.ChallengeToken},
+				"challenge_expires_at": {strconv.FormatInt(result.ChallengeExpiresAt.Unix(), 10)},
+			}
+			c.Redirect(http.StatusFound, redirectURI+"#"+fragment.Encode())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required":  true,
+			"challenge_token":      result.ChallengeToken,
+			"challenge_expires_at": result.ChallengeExpiresAt,
+		})
+		return
+	}
+
+	h.securityService.RecordLogin(result.User, result.User.Email, ip, userAgent, method, models.LoginResultSuccess)
+	tokens := result.Tokens
+	if redirectURI != "" {
+		// Fragments are not sent to servers, keeping the tokens out of logs
+		fragment := url.Values{
+			"token":              {tokens.AccessToken},
+			"expires_at":         {strconv.FormatInt(tokens.ExpiresAt.Unix(), 10)},
+			"refresh_token":      {tokens.RefreshToken},
+			"refresh_expires_at": {strconv.FormatInt(tokens.RefreshExpiresAt.Unix(), 10)},
+		}
+		c.Redirect(http.StatusFound, redirectURI+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
 	v1 "github.com/piyushsharma67/codepushserver/services/v1"
 	"github.com/piyushsharma67/codepushserver/utils"
 )
@@ -51,6 +52,7 @@ func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
 			"name":         org.Name,
 			"description":  org.Description,
 			"public_token": org.PublicToken,
+			"require_2fa":  org.Require2FA,
 			"created_at":   org.CreatedAt,
 		},
 	})
@@ -75,6 +77,10 @@ func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
+		if err == v1.ErrTwoFactorRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication"})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
 		return
 	}
@@ -84,6 +90,7 @@ func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
 		"name":         org.Name,
 		"description":  org.Description,
 		"public_token": org.PublicToken,
+		"require_2fa":  org.Require2FA,
 		"created_at":   org.CreatedAt,
 	})
 }
@@ -108,6 +115,7 @@ func (h *OrganizationHandler) GetUserOrganizations(c *gin.Context) {
 			"name":         org.Name,
 			"description":  org.Description,
 			"public_token": org.PublicToken,
+			"require_2fa":  org.Require2FA,
 			"created_at":   org.CreatedAt,
 		})
 	}
@@ -147,6 +155,14 @@ func (h *OrganizationHandler) InviteUser(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
+		if err == v1.ErrTwoFactorRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication"})
+			return
+		}
+		if err == models.ErrInvalidRole {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin or developer"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite user"})
 		return
 	}
@@ -185,6 +201,81 @@ func (h *OrganizationHandler) GetPendingInvites(c *gin.Context) {
 	})
 }
 
+type AcceptInviteRequest struct {
+	InviteID uint `json:"invite_id" binding:"required"`
+}
+
+// respondInviteError maps errors answering an invitation to HTTP responses
+func respondInviteError(c *gin.Context, err error, message string) {
+	switch err {
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case utils.ErrAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this organization"})
+	case v1.ErrInvitationAnswered:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case v1.ErrInviteEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case v1.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication, enable it before joining"})
+	case models.ErrInvalidRole:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invitation has an invalid role"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// AcceptInvite joins the organization of an invitation sent to the user's
+// email, with the role it was sent with
+func (h *OrganizationHandler) AcceptInvite(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member, err := h.orgService.AcceptInvite(userID, req.InviteID)
+	if err != nil {
+		respondInviteError(c, err, "Failed to accept invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Invitation accepted successfully",
+		"organization_id": member.OrganizationID,
+		"role":            member.Role,
+	})
+}
+
+func (h *OrganizationHandler) DeclineInvite(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.orgService.DeclineInvite(userID, req.InviteID); err != nil {
+		respondInviteError(c, err, "Failed to decline invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invitation declined",
+	})
+}
+
 func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	if userID == 0 {
@@ -204,6 +295,10 @@ func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
+		if err == v1.ErrTwoFactorRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
 		return
 	}
@@ -213,6 +308,52 @@ func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
 	})
 }
 
+type SetRequire2FARequest struct {
+	Require bool `json:"require"`
+}
+
+// SetRequire2FA sets whether organization members must use two-factor
+// authentication
+func (h *OrganizationHandler) SetRequire2FA(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var req SetRequire2FARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.SetRequire2FA(userID, orgID, req.Require)
+	if err != nil {
+		if err == utils.ErrAccessDenied {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		if err == v1.ErrTwoFactorRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Enable two-factor authentication on your account first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          org.ID,
+		"name":        org.Name,
+		"require_2fa": org.Require2FA,
+	})
+}
+
 type TransferAdminRequest struct {
 	NewAdminID uint `json:"new_admin_id" binding:"required"`
 }
@@ -242,6 +383,10 @@ func (h *OrganizationHandler) TransferAdmin(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 			return
 		}
+		if err == v1.ErrTwoFactorRequired {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer admin role"})
 		return
 	}
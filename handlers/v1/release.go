@@ -0,0 +1,256 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type ReleaseHandler struct {
+	releaseService *v1.ReleaseService
+}
+
+func NewReleaseHandler(db database.Database, store storage.BlobStore) *ReleaseHandler {
+	return &ReleaseHandler{
+		releaseService: v1.NewReleaseService(db, store),
+	}
+}
+
+type CreateReleaseRequest struct {
+	AppVersion  string `form:"app_version" binding:"required"`
+	Description string `form:"description"`
+	IsMandatory bool   `form:"is_mandatory"`
+	Rollout     int    `form:"rollout"`
+	Signature   string `form:"signature"`
+}
+
+type PromoteReleaseRequest struct {
+	Label       string  `json:"label"`
+	Description *string `json:"description"`
+	IsMandatory *bool   `json:"is_mandatory"`
+	Rollout     *int    `json:"rollout"`
+}
+
+type UpdateReleaseRequest struct {
+	Description *string `json:"description"`
+	IsMandatory *bool   `json:"is_mandatory"`
+	IsDisabled  *bool   `json:"is_disabled"`
+	Rollout     *int    `json:"rollout"`
+}
+
+type ReleaseHistoryRequest struct {
+	Page    int `form:"page,default=1" binding:"min=1"`
+	PerPage int `form:"per_page,default=20" binding:"min=1,max=100"`
+}
+
+func releaseResponse(release *models.Release) gin.H {
+	return gin.H{
+		"id":             release.ID,
+		"label":          release.Label,
+		"app_version":    release.AppVersion,
+		"description":    release.Description,
+		"is_mandatory":   release.IsMandatory,
+		"is_disabled":    release.IsDisabled,
+		"package_hash":   release.PackageHash,
+		"size":           release.Size,
+		"signed":         release.Signature != "",
+		"rollout":        release.Rollout,
+		"release_method": release.ReleaseMethod,
+		"original_label": release.OriginalLabel,
+		"released_by":    release.ReleasedBy,
+		"created_at":     release.CreatedAt,
+	}
+}
+
+// respondReleaseError maps service errors to HTTP responses
+func respondReleaseError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case utils.ErrAccessDenied:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deployment not found"})
+	case v1.ErrReleaseNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+	case v1.ErrInvalidPackage, v1.ErrInvalidAppVersion, v1.ErrEmptyPackage, v1.ErrInvalidRollout, v1.ErrRolloutDecreased, v1.ErrSameDeployment:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case v1.ErrSignatureRequired, v1.ErrInvalidSignature, v1.ErrInvalidSigningKey:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case v1.ErrPackageUnchanged, v1.ErrNothingToRollback, v1.ErrRollbackAppVersion:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func (h *ReleaseHandler) CreateRelease(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateReleaseRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileHeader, err := c.FormFile("package")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Package file is required"})
+		return
+	}
+
+	pkg, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read package file"})
+		return
+	}
+	defer pkg.Close()
+
+	release, err := h.releaseService.CreateRelease(userID, c.Param("id"), c.Param("name"), pkg, v1.ReleaseParams{
+		AppVersion:  req.AppVersion,
+		Description: req.Description,
+		IsMandatory: req.IsMandatory,
+		Rollout:     req.Rollout,
+		Signature:   req.Signature,
+	})
+	if err != nil {
+		respondReleaseError(c, err, "Failed to create release")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Release created successfully",
+		"release": releaseResponse(release),
+	})
+}
+
+func (h *ReleaseHandler) PromoteRelease(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// All fields are optional, so an empty body promotes the latest release as is
+	var req PromoteReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	release, err := h.releaseService.PromoteRelease(userID, c.Param("id"), c.Param("name"), c.Param("dst"), v1.PromoteParams{
+		Label:       req.Label,
+		Description: req.Description,
+		IsMandatory: req.IsMandatory,
+		Rollout:     req.Rollout,
+	})
+	if err != nil {
+		respondReleaseError(c, err, "Failed to promote release")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Release promoted successfully",
+		"release": releaseResponse(release),
+	})
+}
+
+// RollbackRelease rolls the deployment back to the release given by the
+// optional label parameter, or to the previous release
+func (h *ReleaseHandler) RollbackRelease(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	release, err := h.releaseService.RollbackRelease(userID, c.Param("id"), c.Param("name"), c.Param("label"))
+	if err != nil {
+		respondReleaseError(c, err, "Failed to roll back release")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Deployment rolled back successfully",
+		"release": releaseResponse(release),
+	})
+}
+
+func (h *ReleaseHandler) UpdateRelease(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdateReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	release, err := h.releaseService.UpdateRelease(userID, c.Param("id"), c.Param("name"), c.Param("label"), v1.UpdateReleaseParams{
+		Description: req.Description,
+		IsMandatory: req.IsMandatory,
+		IsDisabled:  req.IsDisabled,
+		Rollout:     req.Rollout,
+	})
+	if err != nil {
+		respondReleaseError(c, err, "Failed to update release")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Release updated successfully",
+		"release": releaseResponse(release),
+	})
+}
+
+func (h *ReleaseHandler) GetHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req ReleaseHistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.releaseService.GetHistory(userID, c.Param("id"), c.Param("name"), req.Page, req.PerPage)
+	if err != nil {
+		respondReleaseError(c, err, "Failed to fetch release history")
+		return
+	}
+
+	responseReleases := []gin.H{}
+	for _, release := range history.Releases {
+		response := releaseResponse(release)
+		if user := history.Users[release.ReleasedBy]; user != nil {
+			// Service accounts are named rather than shown by their placeholder email
+			if user.IsServiceAccount {
+				response["released_by_service_account"] = user.Username
+			} else {
+				response["released_by_email"] = user.Email
+			}
+		}
+		responseReleases = append(responseReleases, response)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"releases": responseReleases,
+		"page":     req.Page,
+		"per_page": req.PerPage,
+		"total":    history.Total,
+	})
+}
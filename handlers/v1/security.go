@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+)
+
+type SecurityHandler struct {
+	securityService *v1.SecurityService
+}
+
+func NewSecurityHandler(db database.Database) *SecurityHandler {
+	return &SecurityHandler{
+		securityService: v1.NewSecurityService(db),
+	}
+}
+
+// GetSecurityEvents lists the latest sign in attempts on the user's account,
+// so they can spot sign ins they do not recognise
+func (h *SecurityHandler) GetSecurityEvents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+
+	events, err := h.securityService.GetSecurityEvents(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	responseEvents := []gin.H{}
+	for _, event := range events {
+		responseEvents = append(responseEvents, gin.H{
+			"id":         event.ID,
+			"method":     event.Method,
+			"result":     event.Result,
+			"ip":         event.IP,
+			"user_agent": event.UserAgent,
+			"created_at": event.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": responseEvents,
+	})
+}
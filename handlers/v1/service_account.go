@@ -0,0 +1,303 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService *v1.ServiceAccountService
+}
+
+func NewServiceAccountHandler(db database.Database) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: v1.NewServiceAccountService(db),
+	}
+}
+
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" binding:"required,max=128"`
+}
+
+type CreateServiceAccountGrantRequest struct {
+	AppID      string `json:"app_id" binding:"required"`
+	Deployment string `json:"deployment"` // all deployments if empty
+}
+
+func serviceAccountResponse(account *models.ServiceAccount) gin.H {
+	return gin.H{
+		"id":         account.ID,
+		"name":       account.Name,
+		"created_by": account.CreatedBy,
+		"created_at": account.CreatedAt,
+	}
+}
+
+func serviceAccountGrantResponse(grant *models.ServiceAccountGrant) gin.H {
+	return gin.H{
+		"id":         grant.ID,
+		"app_id":     grant.AppID,
+		"deployment": grant.DeploymentName,
+		"created_by": grant.CreatedBy,
+		"created_at": grant.CreatedAt,
+	}
+}
+
+// serviceAccountError responds with the status matching a service account
+// error
+func serviceAccountError(c *gin.Context, err error, notFound, message string) {
+	switch err {
+	case utils.ErrAccessDenied:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	case v1.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This organization requires two-factor authentication"})
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case utils.ErrAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Grant already exists"})
+	case v1.ErrServiceAccountScope, v1.ErrInvalidScope, v1.ErrInvalidExpiry:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// serviceAccountParams reads the user, the organization ID and, if present,
+// the service account ID of a request, responding with an error if any is
+// invalid
+func serviceAccountParams(c *gin.Context) (uint, uuid.UUID, uint, bool) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, uuid.Nil, 0, false
+	}
+
+	orgID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, uuid.Nil, 0, false
+	}
+
+	var accountID uint64
+	if value := c.Param("account_id"); value != "" {
+		if accountID, err = strconv.ParseUint(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+			return 0, uuid.Nil, 0, false
+		}
+	}
+
+	return userID, orgID, uint(accountID), true
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	userID, orgID, _, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := h.serviceAccountService.CreateServiceAccount(userID, orgID, req.Name)
+	if err != nil {
+		serviceAccountError(c, err, "Organization not found", "Failed to create service account")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "Service account created successfully",
+		"service_account": serviceAccountResponse(account),
+	})
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	userID, orgID, _, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.serviceAccountService.GetServiceAccounts(userID, orgID)
+	if err != nil {
+		serviceAccountError(c, err, "Organization not found", "Failed to fetch service accounts")
+		return
+	}
+
+	responseAccounts := []gin.H{}
+	for _, account := range accounts {
+		responseAccounts = append(responseAccounts, serviceAccountResponse(account))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"service_accounts": responseAccounts,
+	})
+}
+
+// DeleteServiceAccount revokes a service account's keys and removes it from
+// the organization. Releases it made keep showing its name.
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountService.DeleteServiceAccount(userID, orgID, accountID); err != nil {
+		serviceAccountError(c, err, "Service account not found", "Failed to delete service account")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Service account deleted successfully",
+	})
+}
+
+func (h *ServiceAccountHandler) CreateAccessKey(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAccessKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := h.serviceAccountService.CreateAccessKey(userID, orgID, accountID, req.Name, req.Scope, req.ExpiresAt)
+	if err != nil {
+		serviceAccountError(c, err, "Service account not found", "Failed to create access key")
+		return
+	}
+
+	// The key is only ever returned here
+	response := accessKeyResponse(key)
+	response["key"] = secret
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Access key created successfully",
+		"access_key": response,
+	})
+}
+
+func (h *ServiceAccountHandler) GetAccessKeys(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.serviceAccountService.GetAccessKeys(userID, orgID, accountID)
+	if err != nil {
+		serviceAccountError(c, err, "Service account not found", "Failed to fetch access keys")
+		return
+	}
+
+	responseKeys := []gin.H{}
+	for _, key := range keys {
+		responseKeys = append(responseKeys, accessKeyResponse(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_keys": responseKeys,
+	})
+}
+
+func (h *ServiceAccountHandler) RevokeAccessKey(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("key_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access key ID"})
+		return
+	}
+
+	if err := h.serviceAccountService.RevokeAccessKey(userID, orgID, accountID, uint(keyID)); err != nil {
+		serviceAccountError(c, err, "Access key not found", "Failed to revoke access key")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Access key revoked successfully",
+	})
+}
+
+// CreateGrant allows a service account to release to one of the caller's
+// apps
+func (h *ServiceAccountHandler) CreateGrant(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	var req CreateServiceAccountGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grant, err := h.serviceAccountService.AddGrant(userID, orgID, accountID, req.AppID, req.Deployment)
+	if err != nil {
+		serviceAccountError(c, err, "Service account, app or deployment not found", "Failed to create grant")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Grant created successfully",
+		"grant":   serviceAccountGrantResponse(grant),
+	})
+}
+
+func (h *ServiceAccountHandler) GetGrants(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	grants, err := h.serviceAccountService.GetGrants(userID, orgID, accountID)
+	if err != nil {
+		serviceAccountError(c, err, "Service account not found", "Failed to fetch grants")
+		return
+	}
+
+	responseGrants := []gin.H{}
+	for _, grant := range grants {
+		responseGrants = append(responseGrants, serviceAccountGrantResponse(grant))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"grants": responseGrants,
+	})
+}
+
+func (h *ServiceAccountHandler) DeleteGrant(c *gin.Context) {
+	userID, orgID, accountID, ok := serviceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	grantID, err := strconv.ParseUint(c.Param("grant_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grant ID"})
+		return
+	}
+
+	if err := h.serviceAccountService.RemoveGrant(userID, orgID, accountID, uint(grantID)); err != nil {
+		serviceAccountError(c, err, "Grant not found", "Failed to delete grant")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Grant deleted successfully",
+	})
+}
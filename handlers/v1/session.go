@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/services"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type SessionHandler struct {
+	tokenService *v1.TokenService
+}
+
+func NewSessionHandler(db database.Database, jwtService *services.JWTService) *SessionHandler {
+	return &SessionHandler{
+		tokenService: v1.NewTokenService(db, jwtService),
+	}
+}
+
+// currentSessionID returns the session of the token authenticating the
+// request, or "" for access keys
+func currentSessionID(c *gin.Context) string {
+	if value, ok := c.Get("token_claims"); ok {
+		return value.(*services.TokenClaims).SessionID
+	}
+	return ""
+}
+
+// GetSessions lists the devices the user is signed in on
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := h.tokenService.GetSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := currentSessionID(c)
+	responseSessions := []gin.H{}
+	for _, session := range sessions {
+		responseSessions = append(responseSessions, gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": responseSessions,
+	})
+}
+
+// RevokeSession signs the user out of one device. Its access tokens are
+// rejected from the next request on.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.tokenService.RevokeSession(userID, c.Param("id")); err != nil {
+		if err == utils.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
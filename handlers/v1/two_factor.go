@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/services"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *v1.TwoFactorService
+}
+
+func NewTwoFactorHandler(db database.Database, jwtService *services.JWTService, cfg *config.Config) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: v1.NewTwoFactorService(db, jwtService, cfg),
+	}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// twoFactorError responds with the status matching a two-factor error
+func twoFactorError(c *gin.Context, err error, message string) {
+	switch err {
+	case utils.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case v1.ErrTwoFactorEnabled, v1.ErrTwoFactorNotEnabled, v1.ErrTwoFactorNotEnrolled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case v1.ErrInvalidTwoFactorCode:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case v1.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": "An organization you belong to requires two-factor authentication"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	enabled, remaining, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		twoFactorError(c, err, "Failed to fetch two-factor status")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// Enroll starts setting up two-factor authentication, returning the secret
+// to add to an authenticator app
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	secret, uri, err := h.twoFactorService.Enroll(userID)
+	if err != nil {
+		twoFactorError(c, err, "Failed to set up two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri,
+	})
+}
+
+// Confirm enables two-factor authentication with a first code from the
+// authenticator app. The recovery codes are only shown in this response.
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(userID, req.Code)
+	if err != nil {
+		twoFactorError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Code); err != nil {
+		twoFactorError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		twoFactorError(c, err, "Failed to generate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -39,12 +39,13 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":           user.ID,
-		"username":     user.Username,
-		"email":        user.Email,
-		"company_name": user.CompanyName,
-		"phone_number": user.PhoneNumber,
-		"created_at":   user.CreatedAt,
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"company_name":   user.CompanyName,
+		"phone_number":   user.PhoneNumber,
+		"created_at":     user.CreatedAt,
 	})
 }
 
@@ -68,12 +69,13 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":           user.ID,
-		"username":     user.Username,
-		"email":        user.Email,
-		"company_name": user.CompanyName,
-		"phone_number": user.PhoneNumber,
-		"created_at":   user.CreatedAt,
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"company_name":   user.CompanyName,
+		"phone_number":   user.PhoneNumber,
+		"created_at":     user.CreatedAt,
 	})
 }
 
@@ -108,6 +110,7 @@ func (h *UserHandler) CreateApp(c *gin.Context) {
 			"name":        app.Name,
 			"description": app.Description,
 			"token":       app.Token,
+			"deployments": app.Deployments,
 			"created_at":  app.CreatedAt,
 		},
 	})
@@ -170,6 +173,42 @@ func (h *UserHandler) UpdateApp(c *gin.Context) {
 	})
 }
 
+type SigningKeyRequest struct {
+	PublicKey string `json:"public_key"`
+}
+
+func (h *UserHandler) SetAppSigningKey(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req SigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app, err := h.userService.SetAppSigningKey(userID, c.Param("id"), req.PublicKey)
+	if err != nil {
+		switch err {
+		case utils.ErrAccessDenied:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		case v1.ErrInvalidSigningKey:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Signing key updated successfully",
+		"signing_public_key": app.SigningPublicKey,
+	})
+}
+
 func (h *UserHandler) DeleteApp(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	if userID == 0 {
@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users, such as verification and password reset links
+type Mailer interface {
+	// Send delivers a message from the configured sender address
+	Send(msg *Message) error
+}
+
+// NewMailer creates a new mailer based on the configuration
+func NewMailer(config *config.Config) (Mailer, error) {
+	switch config.MailerType {
+	case "smtp":
+		return NewSMTPMailer(SMTPOptions{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		})
+	case "file":
+		return NewFileMailer(config.MailDir, config.MailFrom)
+	default:
+		return NewLogMailer(config.MailFrom), nil
+	}
+}
+
+// format renders a message in RFC 5322 format
+func format(from string, msg *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validate rejects header values that would let a caller inject headers
+func validate(msg *Message) error {
+	if msg.To == "" || strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email recipient or subject")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPOptions configures an SMTPMailer
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends emails through an SMTP server, using STARTTLS when the
+// server supports it
+type SMTPMailer struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	envelope string // bare sender address
+}
+
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	if opts.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if opts.From == "" {
+		return nil, errors.New("sender address is required")
+	}
+	sender, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Port == 0 {
+		opts.Port = 587
+	}
+
+	m := &SMTPMailer{
+		addr:     net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)),
+		from:     opts.From,
+		envelope: sender.Address,
+	}
+	if opts.Username != "" {
+		m.auth = smtp.PlainAuth("", opts.Username, opts.Password, opts.Host)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.envelope, []string{msg.To}, format(m.from, msg))
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/piyushsharma67/codepushserver/config"
 	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/mailer"
 	"github.com/piyushsharma67/codepushserver/routes"
+	"github.com/piyushsharma67/codepushserver/services"
+	"github.com/piyushsharma67/codepushserver/storage"
 )
 
 func main() {
@@ -32,13 +35,36 @@ func main() {
 	}
 	log.Println("Database migrations completed successfully")
 
+	// Initialize blob storage
+	store, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Initialize token signing
+	jwtService, err := services.NewJWTService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize JWT signing: %v", err)
+	}
+
+	// Initialize email delivery
+	mail, err := mailer.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
 	// Initialize router
 	router := gin.Default()
+	// No proxy is trusted unless configured, so X-Forwarded-For cannot be
+	// spoofed to get around sign in throttling
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
 	// Configure CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5174"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -46,7 +72,7 @@ func main() {
 	}))
 
 	// Setup routes
-	routes.SetupRoutes(router, db)
+	routes.SetupRoutes(router, db, store, cfg, jwtService, mail)
 
 	// Create server
 	srv := &http.Server{
@@ -1,15 +1,22 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
 	"github.com/piyushsharma67/codepushserver/services"
+	v1 "github.com/piyushsharma67/codepushserver/services/v1"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware authenticates requests with either a JWT from login or an
+// access key. JWTs carry the admin scope; access keys carry their own.
+func AuthMiddleware(db database.Database, jwtService *services.JWTService) gin.HandlerFunc {
+	accessKeyService := v1.NewAccessKeyService(db)
+	tokenService := v1.NewTokenService(db, jwtService)
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -26,19 +33,58 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Validate the token
-		jwtService := services.NewJWTService()
-		userID, err := jwtService.ValidateToken(parts[1])
+		if strings.HasPrefix(parts[1], models.AccessKeyPrefix) {
+			key, err := accessKeyService.Authenticate(parts[1])
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access key"})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", key.UserID)
+			c.Set("access_key_id", key.ID)
+			c.Set("scope", key.Scope)
+			c.Next()
+			return
+		}
 
-		fmt.Println("userID**", userID)
+		// Validate the token
+		claims, err := jwtService.ValidateToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := tokenService.IsRevoked(claims, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Set user ID in context
-		c.Set("user_id", userID)
+		c.Set("user_id", claims.UserID)
+		c.Set("token_claims", claims)
+		c.Set("scope", models.ScopeAdmin)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose credentials lack the given scope. It
+// must run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !v1.ScopeAllows(c.GetString("scope"), scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access key does not have the " + scope + " scope"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Access key scopes, from least to most privileged. Each scope includes the
+// permissions of the ones before it.
+const (
+	ScopeReadOnly = "read-only"
+	ScopeRelease  = "release"
+	ScopeAdmin    = "admin"
+)
+
+// AccessKeyPrefix marks access keys so they can be told apart from JWTs
+const AccessKeyPrefix = "cpk_"
+
+// AccessKey is a long-lived credential for the CLI and CI pipelines. Only the
+// SHA-256 of the key is stored; the key itself is shown once on creation.
+type AccessKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"size:128;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16;not null"` // first characters of the key, to help users recognise it
+	KeyHash    string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Scope      string     `json:"scope" gorm:"size:16;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -3,12 +3,14 @@ package models
 import "time"
 
 type App struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
-	UserID      uint      `json:"user_id" gorm:"not null"`
-	Name        string    `json:"name" gorm:"not null"`
-	Description string    `json:"description"`
-	Platform    string    `json:"platform" gorm:"not null"`
-	Token       string    `json:"token" gorm:"unique;not null"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-} 
\ No newline at end of file
+	ID               string       `json:"id" gorm:"primaryKey"`
+	UserID           uint         `json:"user_id" gorm:"not null"`
+	Name             string       `json:"name" gorm:"not null"`
+	Description      string       `json:"description"`
+	Platform         string       `json:"platform" gorm:"not null"`
+	Token            string       `json:"token" gorm:"unique;not null"`
+	SigningPublicKey string       `json:"signing_public_key,omitempty" gorm:"type:text"` // PEM key release signatures are verified with
+	Deployments      []Deployment `json:"deployments,omitempty" gorm:"foreignKey:AppID"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	DeploymentStaging    = "Staging"
+	DeploymentProduction = "Production"
+)
+
+// DefaultDeployments are created for every new app
+var DefaultDeployments = []string{DeploymentStaging, DeploymentProduction}
+
+type Deployment struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	AppID            string    `json:"app_id" gorm:"size:64;not null;uniqueIndex:idx_app_deployment_name"`
+	Name             string    `json:"name" gorm:"size:128;not null;uniqueIndex:idx_app_deployment_name"`
+	Key              string    `json:"key" gorm:"size:128;unique;not null"`
+	LastLabelNumber  int       `json:"-" gorm:"not null;default:0"`                   // number of the last assigned label (v1, v2, ...)
+	SigningPublicKey string    `json:"signing_public_key,omitempty" gorm:"type:text"` // overrides the app's key when set
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"size:64;not null;uniqueIndex:idx_identity"`
+	Subject   string    `json:"subject" gorm:"size:191;not null;uniqueIndex:idx_identity"` // the provider's user ID
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OIDCLoginState holds a sign in in progress between redirecting the user to
+// the provider and its callback. It is deleted when the callback uses it.
+type OIDCLoginState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	State        string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `json:"provider" gorm:"size:64;not null"`
+	CodeVerifier string    `json:"-" gorm:"size:128;not null"` // PKCE verifier
+	Nonce        string    `json:"-" gorm:"size:64;not null"`
+	RedirectURI  string    `json:"redirect_uri"` // where the client wants the tokens sent
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Login methods
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTwoFactor = "two_factor"
+	LoginMethodOIDC      = "oidc" // followed by ":<provider>"
+)
+
+// Login results. Only failures and pending attempts count towards throttling
+// and lockouts.
+const (
+	LoginResultPending           = "pending" // recorded before the credentials are checked
+	LoginResultSuccess           = "success"
+	LoginResultFailure           = "failure"
+	LoginResultBlocked           = "blocked" // refused without checking credentials, due to too many failures
+	LoginResultTwoFactorRequired = "two_factor_required"
+	LoginResultError             = "error" // the server failed to complete the attempt
+)
+
+// LoginEvent records a sign in attempt so failures can be throttled and users
+// can review recent activity on their account
+type LoginEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"index"` // 0 if no account has the email
+	Email     string    `json:"-" gorm:"size:191;index"`
+	Method    string    `json:"method" gorm:"size:64;not null"`
+	Result    string    `json:"result" gorm:"size:32;not null"`
+	IP        string    `json:"ip" gorm:"size:64;index"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
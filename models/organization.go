@@ -13,10 +13,18 @@ type Organization struct {
 	PublicToken  string    `json:"public_token"`
 	PrivateToken string    `json:"private_token,omitempty"`
 	CreatedBy    uint      `json:"created_by"`
+	Require2FA   bool      `json:"require_2fa" gorm:"column:require_2fa;not null;default:false"` // members must enable two-factor authentication
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Invitation statuses
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+)
+
 type OrganizationInvitation struct {
 	ID             uint      `json:"id" gorm:"primaryKey"`
 	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null"`
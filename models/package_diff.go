@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PackageDiff is a cached differential package that upgrades a client from
+// one package to another. It contains only changed and added files plus a
+// hotcodepush.json manifest listing deleted files.
+type PackageDiff struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	FromPackageHash string    `json:"from_package_hash" gorm:"size:64;not null;uniqueIndex:idx_package_diff"`
+	ToPackageHash   string    `json:"to_package_hash" gorm:"size:64;not null"`
+	ToBlobPath      string    `json:"-" gorm:"size:191;not null;uniqueIndex:idx_package_diff"` // signed variants of a package have their own diffs
+	BlobPath        string    `json:"-" gorm:"not null"`
+	Size            int64     `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Release methods describe how a release was created
+const (
+	ReleaseMethodUpload   = "Upload"
+	ReleaseMethodPromote  = "Promote"
+	ReleaseMethodRollback = "Rollback"
+)
+
+type Release struct {
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	DeploymentID       uint      `json:"deployment_id" gorm:"not null;uniqueIndex:idx_deployment_label"`
+	Label              string    `json:"label" gorm:"size:32;not null;uniqueIndex:idx_deployment_label"`
+	AppVersion         string    `json:"app_version" gorm:"not null"` // target binary version range, e.g. "1.2.x"
+	Description        string    `json:"description"`
+	IsMandatory        bool      `json:"is_mandatory"`
+	IsDisabled         bool      `json:"is_disabled"`
+	PackageHash        string    `json:"package_hash" gorm:"size:64;not null;index"`
+	BlobPath           string    `json:"-" gorm:"not null"`
+	Size               int64     `json:"size"`
+	Signature          string    `json:"signature,omitempty" gorm:"type:text"` // code signing JWT embedded in the package
+	Rollout            int       `json:"rollout" gorm:"not null;default:100"`  // percentage of clients receiving the release
+	ReleaseMethod      string    `json:"release_method" gorm:"size:32;not null"`
+	OriginalLabel      string    `json:"original_label,omitempty"`      // label the release was promoted from or rolled back to
+	OriginalDeployment string    `json:"original_deployment,omitempty"` // deployment the release was promoted from
+	ReleasedBy         uint      `json:"released_by"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
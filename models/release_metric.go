@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Counters tracked per release label (or binary app version) of a deployment
+const (
+	MetricActive     = "active"
+	MetricDownloaded = "downloaded"
+	MetricInstalled  = "installed"
+	MetricFailed     = "failed"
+	MetricRollbacks  = "rollbacks"
+)
+
+// ReleaseMetric aggregates the status reports of a deployment for one label.
+// Clients running the binary version are counted under their app version.
+type ReleaseMetric struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	DeploymentID uint      `json:"deployment_id" gorm:"not null;uniqueIndex:idx_release_metric"`
+	Label        string    `json:"label" gorm:"size:64;not null;uniqueIndex:idx_release_metric"`
+	Active       int64     `json:"active" gorm:"not null;default:0"`
+	Downloaded   int64     `json:"downloaded" gorm:"not null;default:0"`
+	Installed    int64     `json:"installed" gorm:"not null;default:0"`
+	Failed       int64     `json:"failed" gorm:"not null;default:0"`
+	Rollbacks    int64     `json:"rollbacks" gorm:"not null;default:0"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccount is a non-human member of an organization, such as a CI
+// pipeline. It is backed by a user flagged as a service account, so it
+// authenticates with access keys and passes the same membership checks as
+// people, but it cannot sign in.
+type ServiceAccount struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uuid.UUID  `json:"organization_id" gorm:"type:uuid;not null;index"`
+	UserID         uint       `json:"user_id" gorm:"not null;uniqueIndex"`
+	Name           string     `json:"name" gorm:"size:128;not null"`
+	CreatedBy      uint       `json:"created_by"`
+	DeletedAt      *time.Time `json:"deleted_at"` // kept after deletion so release history can still name it
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ServiceAccountGrant allows a service account to release to an app, either
+// to one deployment or, with an empty deployment name, to all of them
+type ServiceAccountGrant struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	ServiceAccountID uint      `json:"service_account_id" gorm:"not null;uniqueIndex:idx_service_account_grant"`
+	AppID            string    `json:"app_id" gorm:"size:64;not null;uniqueIndex:idx_service_account_grant"`
+	DeploymentName   string    `json:"deployment_name" gorm:"size:128;not null;default:'';uniqueIndex:idx_service_account_grant"`
+	CreatedBy        uint      `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
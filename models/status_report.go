@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Kinds of status reports sent by the CodePush SDK
+const (
+	StatusReportDeploy   = "deploy"
+	StatusReportDownload = "download"
+)
+
+// Deployment statuses reported by the CodePush SDK after an install
+const (
+	StatusDeploymentSucceeded = "DeploymentSucceeded"
+	StatusDeploymentFailed    = "DeploymentFailed"
+)
+
+// StatusReport is a single deploy or download event reported by a client
+type StatusReport struct {
+	ID                        uint      `json:"id" gorm:"primaryKey"`
+	DeploymentID              uint      `json:"deployment_id" gorm:"not null;index:idx_status_report_release"`
+	ReleaseID                 *uint     `json:"release_id" gorm:"index:idx_status_report_release"` // nil when reporting the binary version
+	Kind                      string    `json:"kind" gorm:"size:16;not null"`
+	Label                     string    `json:"label"`
+	AppVersion                string    `json:"app_version"`
+	PreviousDeploymentKey     string    `json:"previous_deployment_key"`
+	PreviousLabelOrAppVersion string    `json:"previous_label_or_app_version"`
+	Status                    string    `json:"status" gorm:"size:32"`
+	ClientUniqueID            string    `json:"client_unique_id"`
+	CreatedAt                 time.Time `json:"created_at"`
+}
@@ -5,15 +5,20 @@ import (
 )
 
 type User struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Username    string    `json:"username" gorm:"not null"`
-	Email       string    `json:"email" gorm:"unique;not null"`
-	Password    string    `json:"-" gorm:"not null"`
-	CompanyName string    `json:"company_name"`
-	PhoneNumber string    `json:"phone_number"`
-	Apps        []App     `json:"apps" gorm:"foreignKey:UserID"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Username         string    `json:"username" gorm:"not null"`
+	Email            string    `json:"email" gorm:"unique;not null"`
+	EmailVerified    bool      `json:"email_verified" gorm:"not null;default:false"`
+	Password         string    `json:"-" gorm:"not null"`
+	CompanyName      string    `json:"company_name"`
+	PhoneNumber      string    `json:"phone_number"`
+	Apps             []App     `json:"apps" gorm:"foreignKey:UserID"`
+	TOTPSecret       string    `json:"-"` // set on enrollment, used once TOTPEnabled
+	TOTPEnabled      bool      `json:"totp_enabled" gorm:"not null;default:false"`
+	TOTPLastStep     int64     `json:"-"` // time step of the last accepted code, so codes cannot be reused
+	IsServiceAccount bool      `json:"is_service_account" gorm:"not null;default:false"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 type UserRepository interface {
@@ -29,4 +34,4 @@ type AppRepository interface {
 	FindByUserID(userID uint) ([]App, error)
 	Update(app *App) error
 	Delete(id uint) error
-} 
\ No newline at end of file
+}
@@ -2,16 +2,49 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/piyushsharma67/codepushserver/config"
 	"github.com/piyushsharma67/codepushserver/database"
 	v1 "github.com/piyushsharma67/codepushserver/handlers/v1"
+	"github.com/piyushsharma67/codepushserver/mailer"
 	"github.com/piyushsharma67/codepushserver/middleware"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	"github.com/piyushsharma67/codepushserver/storage"
 )
 
-func SetupRoutes(router *gin.Engine, db database.Database) {
+func SetupRoutes(router *gin.Engine, db database.Database, store storage.BlobStore, cfg *config.Config, jwtService *services.JWTService, mail mailer.Mailer) {
 	// Initialize handlers
-	authHandler := v1.NewAuthHandler(db)
+	authHandler := v1.NewAuthHandler(db, jwtService, mail, cfg)
 	userHandler := v1.NewUserHandler(db)
 	orgHandler := v1.NewOrganizationHandler(db)
+	deploymentHandler := v1.NewDeploymentHandler(db)
+	releaseHandler := v1.NewReleaseHandler(db, store)
+	acquisitionHandler := v1.NewAcquisitionHandler(db, store, cfg)
+	metricsHandler := v1.NewMetricsHandler(db)
+	downloadHandler := v1.NewDownloadHandler(db, store)
+	accessKeyHandler := v1.NewAccessKeyHandler(db)
+	oidcHandler := v1.NewOIDCHandler(db, jwtService, cfg)
+	twoFactorHandler := v1.NewTwoFactorHandler(db, jwtService, cfg)
+	securityHandler := v1.NewSecurityHandler(db)
+	sessionHandler := v1.NewSessionHandler(db, jwtService)
+	serviceAccountHandler := v1.NewServiceAccountHandler(db)
+
+	// CodePush SDK routes (public, authenticated by deployment key)
+	router.GET("/updateCheck", acquisitionHandler.LegacyUpdateCheck)
+	router.GET("/v0.1/public/codepush/update_check", acquisitionHandler.UpdateCheck)
+	router.POST("/reportStatus/deploy", acquisitionHandler.LegacyReportDeploy)
+	router.POST("/reportStatus/download", acquisitionHandler.LegacyReportDownload)
+	router.POST("/v0.1/public/codepush/report_status/deploy", acquisitionHandler.ReportDeploy)
+	router.POST("/v0.1/public/codepush/report_status/download", acquisitionHandler.ReportDownload)
+
+	// Keys for verifying tokens issued by this server
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Blobs in the local store are downloaded from this server
+	if _, ok := store.(*storage.LocalStore); ok {
+		router.GET("/storage/*key", downloadHandler.Download)
+		router.HEAD("/storage/*key", downloadHandler.Download)
+	}
 
 	// API v1 routes
 	v1Group := router.Group("/api/v1")
@@ -19,28 +52,92 @@ func SetupRoutes(router *gin.Engine, db database.Database) {
 		// Auth routes (public)
 		v1Group.POST("/auth/register", authHandler.Register)
 		v1Group.POST("/auth/login", authHandler.Login)
+		v1Group.POST("/auth/login/2fa", authHandler.LoginTwoFactor)
+		v1Group.POST("/auth/refresh", authHandler.Refresh)
+		v1Group.POST("/auth/verify-email", authHandler.VerifyEmail)
+		v1Group.POST("/auth/forgot-password", authHandler.ForgotPassword)
+		v1Group.POST("/auth/reset-password", authHandler.ResetPassword)
+		v1Group.GET("/auth/oidc/providers", oidcHandler.GetProviders)
+		v1Group.GET("/auth/oidc/:provider/login", oidcHandler.Login)
+		v1Group.GET("/auth/oidc/:provider/callback", oidcHandler.Callback)
 
-		// Protected routes
+		// Protected routes. Any credential may read; access keys need the
+		// release scope to change releases and the admin scope for the rest.
 		protected := v1Group.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(db, jwtService))
+		release := protected.Group("", middleware.RequireScope(models.ScopeRelease))
+		admin := protected.Group("", middleware.RequireScope(models.ScopeAdmin))
 		{
+			// Auth routes
+			protected.POST("/auth/logout", authHandler.Logout)
+			admin.POST("/auth/verify-email/resend", authHandler.ResendVerificationEmail)
+
 			// User routes
 			protected.GET("/user/profile", userHandler.GetProfile)
-			protected.PUT("/user/profile", userHandler.UpdateProfile)
+			admin.PUT("/user/profile", userHandler.UpdateProfile)
 			protected.GET("/user/apps", userHandler.GetAllApps)
-			protected.POST("/user/apps", userHandler.CreateApp)
+			admin.POST("/user/apps", userHandler.CreateApp)
 			protected.GET("/user/apps/:id", userHandler.GetApp)
-			protected.PUT("/user/apps/:id", userHandler.UpdateApp)
-			protected.DELETE("/user/apps/:id", userHandler.DeleteApp)
+			admin.PUT("/user/apps/:id", userHandler.UpdateApp)
+			admin.DELETE("/user/apps/:id", userHandler.DeleteApp)
+			admin.PUT("/user/apps/:id/signing-key", userHandler.SetAppSigningKey)
+
+			// Security routes
+			protected.GET("/user/security-events", securityHandler.GetSecurityEvents)
+
+			// Session routes
+			protected.GET("/user/sessions", sessionHandler.GetSessions)
+			admin.DELETE("/user/sessions/:id", sessionHandler.RevokeSession)
+
+			// Two-factor authentication routes
+			protected.GET("/user/2fa", twoFactorHandler.GetStatus)
+			admin.POST("/user/2fa/enroll", twoFactorHandler.Enroll)
+			admin.POST("/user/2fa/confirm", twoFactorHandler.Confirm)
+			admin.POST("/user/2fa/disable", twoFactorHandler.Disable)
+			admin.POST("/user/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+
+			// Access key routes
+			protected.GET("/user/access-keys", accessKeyHandler.GetAccessKeys)
+			admin.POST("/user/access-keys", accessKeyHandler.CreateAccessKey)
+			admin.DELETE("/user/access-keys/:id", accessKeyHandler.RevokeAccessKey)
+
+			// Deployment routes
+			protected.GET("/user/apps/:id/deployments", deploymentHandler.GetDeployments)
+			admin.POST("/user/apps/:id/deployments", deploymentHandler.CreateDeployment)
+			protected.GET("/user/apps/:id/deployments/:name", deploymentHandler.GetDeployment)
+			admin.PUT("/user/apps/:id/deployments/:name", deploymentHandler.UpdateDeployment)
+			admin.DELETE("/user/apps/:id/deployments/:name", deploymentHandler.DeleteDeployment)
+
+			// Release routes
+			release.POST("/apps/:id/deployments/:name/releases", releaseHandler.CreateRelease)
+			release.PATCH("/apps/:id/deployments/:name/releases/:label", releaseHandler.UpdateRelease)
+			protected.GET("/apps/:id/deployments/:name/history", releaseHandler.GetHistory)
+			protected.GET("/apps/:id/deployments/:name/metrics", metricsHandler.GetMetrics)
+			release.POST("/apps/:id/deployments/:name/promote/:dst", releaseHandler.PromoteRelease)
+			release.POST("/apps/:id/deployments/:name/rollback", releaseHandler.RollbackRelease)
+			release.POST("/apps/:id/deployments/:name/rollback/:label", releaseHandler.RollbackRelease)
 
 			// Organization routes
-			protected.POST("/organizations", orgHandler.CreateOrganization)
-			protected.POST("/organizations/invite", orgHandler.InviteUser)
-			// protected.POST("/organizations/accept-invite", orgHandler.AcceptInvite)
+			admin.POST("/organizations", orgHandler.CreateOrganization)
+			admin.POST("/organizations/:id/invite", orgHandler.InviteUser)
+			admin.POST("/organizations/accept-invite", orgHandler.AcceptInvite)
+			admin.POST("/organizations/decline-invite", orgHandler.DeclineInvite)
 			protected.GET("/organizations", orgHandler.GetUserOrganizations)
 			protected.GET("/organizations/pending-invites", orgHandler.GetPendingInvites)
-			protected.DELETE("/organizations/:id", orgHandler.DeleteOrganization)
-			protected.POST("/organizations/transfer-admin", orgHandler.TransferAdmin)
+			admin.DELETE("/organizations/:id", orgHandler.DeleteOrganization)
+			admin.PUT("/organizations/:id/require-2fa", orgHandler.SetRequire2FA)
+			admin.POST("/organizations/:id/transfer-admin", orgHandler.TransferAdmin)
+
+			// Service account routes, managed by organization admins
+			protected.GET("/organizations/:id/service-accounts", serviceAccountHandler.GetServiceAccounts)
+			admin.POST("/organizations/:id/service-accounts", serviceAccountHandler.CreateServiceAccount)
+			admin.DELETE("/organizations/:id/service-accounts/:account_id", serviceAccountHandler.DeleteServiceAccount)
+			protected.GET("/organizations/:id/service-accounts/:account_id/access-keys", serviceAccountHandler.GetAccessKeys)
+			admin.POST("/organizations/:id/service-accounts/:account_id/access-keys", serviceAccountHandler.CreateAccessKey)
+			admin.DELETE("/organizations/:id/service-accounts/:account_id/access-keys/:key_id", serviceAccountHandler.RevokeAccessKey)
+			protected.GET("/organizations/:id/service-accounts/:account_id/grants", serviceAccountHandler.GetGrants)
+			admin.POST("/organizations/:id/service-accounts/:account_id/grants", serviceAccountHandler.CreateGrant)
+			admin.DELETE("/organizations/:id/service-accounts/:account_id/grants/:grant_id", serviceAccountHandler.DeleteGrant)
 		}
 	}
 } 
\ No newline at end of file
@@ -1,34 +1,236 @@
 package services
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"github.com/piyushsharma67/codepushserver/config"
 )
 
+// jwtKey is a key tokens are verified with
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// JWTService issues and validates the tokens returned by login. Tokens are
+// signed with the configured key; keys listed in JWTVerifyKeys are still
+// accepted so keys can be rotated without logging everyone out.
 type JWTService struct {
-	secretKey []byte
+	method        jwt.SigningMethod
+	signingKey    interface{}
+	keyID         string
+	keys          []*jwtKey
+	expiry        time.Duration
+	refreshExpiry time.Duration
+}
+
+// TokenClaims are the claims of a validated token
+type TokenClaims struct {
+	UserID    uint
+	ID        string // jti, used to revoke the token
+	SessionID string // sid, the session the token was issued to
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
-func NewJWTService() *JWTService {
-	cfg := config.NewConfig()
-	return &JWTService{
-		secretKey: []byte(cfg.JWTKey),
+func NewJWTService(cfg *config.Config) (*JWTService, error) {
+	if cfg.JWTExpiry <= 0 || cfg.RefreshExpiry <= 0 {
+		return nil, errors.New("token expiry must be positive")
+	}
+	s := &JWTService{
+		keyID:         cfg.JWTKeyID,
+		expiry:        time.Duration(cfg.JWTExpiry) * time.Hour,
+		refreshExpiry: time.Duration(cfg.RefreshExpiry) * 24 * time.Hour,
 	}
+
+	var verifyKey interface{}
+	switch cfg.JWTAlgorithm {
+	case "HS256", "":
+		secret := []byte(cfg.JWTKey)
+		if cfg.JWTKeyFile != "" {
+			data, err := os.ReadFile(cfg.JWTKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(strings.TrimSpace(string(data)))
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("no JWT secret configured, set JWT_SECRET or JWT_KEY_FILE")
+		}
+		s.method, s.signingKey, verifyKey = jwt.SigningMethodHS256, secret, secret
+	case "RS256", "EdDSA":
+		if cfg.JWTKeyFile == "" {
+			return nil, fmt.Errorf("%s requires a private key file", cfg.JWTAlgorithm)
+		}
+		data, err := os.ReadFile(cfg.JWTKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.JWTAlgorithm == "RS256" {
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			s.method, s.signingKey, verifyKey = jwt.SigningMethodRS256, key, &key.PublicKey
+		} else {
+			key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			s.method, s.signingKey, verifyKey = jwt.SigningMethodEdDSA, key, key.(ed25519.PrivateKey).Public()
+		}
+		if s.keyID == "" {
+			s.keyID = keyThumbprint(verifyKey)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+	s.keys = append(s.keys, &jwtKey{id: s.keyID, method: s.method, key: verifyKey})
+
+	if err := s.loadVerifyKeys(cfg.JWTVerifyKeys); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
 
-func (s *JWTService) GenerateToken(userID uint, email string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(time.Hour * 24) // 24 hours
+// loadVerifyKeys reads the comma-separated list of kid=file pairs of keys that
+// are no longer used for signing but still accepted. The kid of public keys
+// defaults to their thumbprint.
+func (s *JWTService) loadVerifyKeys(list string) error {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, file := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			id, file = entry[:i], entry[i+1:]
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		method, key, err := parseVerifyKey(data)
+		if err != nil {
+			return fmt.Errorf("invalid JWT verification key %s: %w", file, err)
+		}
+		if id == "" && method != jwt.SigningMethodHS256 {
+			id = keyThumbprint(key)
+		}
+		s.keys = append(s.keys, &jwtKey{id: id, method: method, key: key})
+	}
+	return nil
+}
+
+// parseVerifyKey accepts a PEM encoded RSA or Ed25519 key, public or private,
+// or otherwise treats the file contents as an HS256 secret
+func parseVerifyKey(data []byte) (jwt.SigningMethod, interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, nil, errors.New("empty secret")
+		}
+		return jwt.SigningMethodHS256, secret, nil
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, key, nil
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, &key.PublicKey, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, key, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, key.Public(), nil
+	default:
+		return nil, nil, errors.New("key must be RSA or Ed25519")
+	}
+}
+
+// publicJWK returns the JSON Web Key members of a public key, or nil for
+// HMAC secrets, which must never be published
+func publicJWK(key interface{}) map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   encode(key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   encode(key),
+		}
+	}
+	return nil
+}
+
+// keyThumbprint returns the RFC 7638 thumbprint of a public key. JSON object
+// keys are marshalled in sorted order, as the RFC requires.
+func keyThumbprint(key interface{}) string {
+	data, _ := json.Marshal(publicJWK(key))
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// GenerateToken signs an access token for a session of a user
+func (s *JWTService) GenerateToken(userID uint, email, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.expiry)
 
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"email":   email,
+		"jti":     uuid.NewString(),
+		"sid":     sessionID,
+		"iat":     now.Unix(),
 		"exp":     expiresAt.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secretKey)
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.keyID != "" {
+		token.Header["kid"] = s.keyID
+	}
+	tokenString, err := token.SignedString(s.signingKey)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -36,19 +238,78 @@ func (s *JWTService) GenerateToken(userID uint, email string) (string, time.Time
 	return tokenString, expiresAt, nil
 }
 
-func (s *JWTService) ValidateToken(tokenString string) (uint, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return s.secretKey, nil
-	})
-
+// candidateKeys returns the keys that may have signed a token: the key named
+// by its kid header, or every key of its algorithm for tokens without one.
+// Matching on the algorithm prevents tokens choosing how a key is used.
+func (s *JWTService) candidateKeys(tokenString string) []*jwtKey {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
 	if err != nil {
-		return 0, err
+		return nil
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	var keys []*jwtKey
+	for _, key := range s.keys {
+		if key.method.Alg() == token.Method.Alg() && (kid == "" || key.id == kid) {
+			keys = append(keys, key)
+		}
 	}
+	return keys
+}
+
+// RefreshExpiry returns how long refresh tokens stay valid
+func (s *JWTService) RefreshExpiry() time.Duration {
+	return s.refreshExpiry
+}
+
+func (s *JWTService) ValidateToken(tokenString string) (*TokenClaims, error) {
+	err := jwt.ErrSignatureInvalid
+	for _, key := range s.candidateKeys(tokenString) {
+		var token *jwt.Token
+		token, err = jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			return key.key, nil
+		})
+		if err != nil {
+			continue
+		}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint(claims["user_id"].(float64))
-		return userID, nil
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+
+		result := &TokenClaims{UserID: uint(userID)}
+		result.ID, _ = claims["jti"].(string)
+		result.SessionID, _ = claims["sid"].(string)
+		if iat, ok := claims["iat"].(float64); ok {
+			result.IssuedAt = time.Unix(int64(iat), 0)
+		}
+		if exp, ok := claims["exp"].(float64); ok {
+			result.ExpiresAt = time.Unix(int64(exp), 0)
+		}
+		return result, nil
 	}
 
-	return 0, jwt.ErrSignatureInvalid
-} 
\ No newline at end of file
+	return nil, err
+}
+
+// JWKS returns the public keys tokens are verified with as a JSON Web Key Set,
+// so other services can verify tokens issued by this server
+func (s *JWTService) JWKS() map[string]interface{} {
+	keys := []map[string]string{}
+	for _, key := range s.keys {
+		jwk := publicJWK(key.key)
+		if jwk == nil {
+			continue
+		}
+		jwk["kid"] = key.id
+		jwk["alg"] = key.method.Alg()
+		jwk["use"] = "sig"
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
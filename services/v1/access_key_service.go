@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var (
+	ErrInvalidScope     = errors.New("scope must be one of read-only, release or admin")
+	ErrInvalidExpiry    = errors.New("expiry must be in the future")
+	ErrInvalidAccessKey = errors.New("invalid access key")
+)
+
+// scopeLevels orders the access key scopes by privilege
+var scopeLevels = map[string]int{
+	models.ScopeReadOnly: 1,
+	models.ScopeRelease:  2,
+	models.ScopeAdmin:    3,
+}
+
+// lastUsedInterval limits how often the last use of a key is written, so busy
+// CI keys do not cause a write on every request
+const lastUsedInterval = time.Minute
+
+// ScopeAllows reports whether a credential with the granted scope may perform
+// an action requiring the required scope
+func ScopeAllows(granted, required string) bool {
+	return scopeLevels[granted] != 0 && scopeLevels[granted] >= scopeLevels[required]
+}
+
+func hashAccessKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type AccessKeyService struct {
+	db database.Database
+}
+
+func NewAccessKeyService(db database.Database) *AccessKeyService {
+	return &AccessKeyService{db: db}
+}
+
+// CreateAccessKey creates a key for a user and returns it along with the
+// plaintext key, which cannot be recovered later
+func (s *AccessKeyService) CreateAccessKey(userID uint, name, scope string, expiresAt *time.Time) (*models.AccessKey, string, error) {
+	if scope == "" {
+		scope = models.ScopeAdmin
+	}
+	if scopeLevels[scope] == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidExpiry
+	}
+
+	secret := models.AccessKeyPrefix + utils.GenerateRandomString(24)
+	key := &models.AccessKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(models.AccessKeyPrefix)+6],
+		KeyHash:   hashAccessKey(secret),
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.CreateAccessKey(key); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *AccessKeyService) GetAccessKeys(userID uint) ([]*models.AccessKey, error) {
+	return s.db.FindAccessKeysByUserID(userID)
+}
+
+// RevokeAccessKey disables a key. Revoked keys are kept so they still show up
+// in the user's key list.
+func (s *AccessKeyService) RevokeAccessKey(userID, keyID uint) error {
+	key, err := s.db.FindAccessKeyByID(keyID)
+	if err != nil {
+		return utils.ErrNotFound
+	}
+	if key.UserID != userID {
+		return utils.ErrAccessDenied
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	return s.db.UpdateAccessKey(key)
+}
+
+// Authenticate resolves a plaintext key to an active access key
+func (s *AccessKeyService) Authenticate(secret string) (*models.AccessKey, error) {
+	key, err := s.db.FindAccessKeyByHash(hashAccessKey(secret))
+	if err != nil {
+		return nil, ErrInvalidAccessKey
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAccessKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedInterval {
+		if err := s.db.TouchAccessKey(key.ID, now); err == nil {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, nil
+}
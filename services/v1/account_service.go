@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/mailer"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	"github.com/piyushsharma67/codepushserver/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidUserToken     = errors.New("invalid or expired token")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+)
+
+const (
+	verifyEmailExpiry   = 24 * time.Hour
+	resetPasswordExpiry = time.Hour
+)
+
+// AccountService handles email verification and password resets, both of
+// which email the user a single use link
+type AccountService struct {
+	db           database.Database
+	mailer       mailer.Mailer
+	tokenService *TokenService
+	appURL       string
+}
+
+func NewAccountService(db database.Database, mail mailer.Mailer, jwtService *services.JWTService, cfg *config.Config) *AccountService {
+	return &AccountService{
+		db:           db,
+		mailer:       mail,
+		tokenService: NewTokenService(db, jwtService),
+		appURL:       strings.TrimSuffix(cfg.AppURL, "/"),
+	}
+}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createToken stores a new token for a user, replacing the user's earlier
+// tokens for the same purpose so only the latest link works, and returns the
+// link to the web app page that uses it
+func (s *AccountService) createToken(user *models.User, purpose, page string, expiry time.Duration) (string, error) {
+	secret := utils.GenerateRandomString(32)
+	token := &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashUserToken(secret),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := s.db.CreateUserToken(token); err != nil {
+		return "", err
+	}
+	return s.appURL + page + "?token=" + url.QueryEscape(secret), nil
+}
+
+// consumeToken resolves a token for the given purpose and marks it used
+func (s *AccountService) consumeToken(secret, purpose string) (*models.User, error) {
+	token, err := s.db.FindUserTokenByHash(hashUserToken(secret))
+	if err != nil || token.Purpose != purpose {
+		return nil, ErrInvalidUserToken
+	}
+
+	now := time.Now()
+	if token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+	marked, err := s.db.MarkUserTokenUsed(token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, ErrInvalidUserToken
+	}
+
+	user, err := s.db.FindUserByID(token.UserID)
+	if err != nil {
+		return nil, ErrInvalidUserToken
+	}
+	return user, nil
+}
+
+// SendVerificationEmail emails a user a link to verify their address
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	link, err := s.createToken(user, models.TokenPurposeVerifyEmail, "/verify-email", verifyEmailExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in 24 hours. If you did not create an account, you can ignore this email.\n",
+			user.Username, link),
+	})
+}
+
+// ResendVerificationEmail sends a new verification link, invalidating the
+// previous one
+func (s *AccountService) ResendVerificationEmail(userID uint) error {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return utils.ErrNotFound
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail marks the email of the user a verification token was sent to
+// as verified
+func (s *AccountService) VerifyEmail(token string) (*models.User, error) {
+	user, err := s.consumeToken(token, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	user.EmailVerified = true
+	if err := s.db.UpdateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ForgotPassword emails a password reset link if an account exists for the
+// address. Neither unknown addresses nor failures to send are reported, so
+// the endpoint cannot be used to find out who has an account; failures are
+// logged instead.
+func (s *AccountService) ForgotPassword(email string) {
+	// Service accounts have no password to reset
+	user, err := s.db.FindUserByEmail(email)
+	if err != nil || user.IsServiceAccount {
+		return
+	}
+
+	link, err := s.createToken(user, models.TokenPurposeResetPassword, "/reset-password", resetPasswordExpiry)
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %d: %v", user.ID, err)
+		return
+	}
+
+	err = s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new password, open this link:\n\n%s\n\n"+
+			"The link expires in 1 hour. If you did not ask for this, you can ignore this email.\n",
+			user.Username, link),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword sets a new password using a reset token and logs out every
+// session, since the old password may have been compromised. Receiving the
+// token proves the user owns the email address, so it is also verified.
+func (s *AccountService) ResetPassword(token, password string) error {
+	user, err := s.consumeToken(token, models.TokenPurposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashedPassword)
+	user.EmailVerified = true
+	if err := s.db.UpdateUser(user); err != nil {
+		return err
+	}
+
+	return s.tokenService.LogoutAll(user.ID)
+}
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var ErrInvalidStatus = errors.New("invalid deployment status")
+
+// AcquisitionService implements the public endpoints used by the CodePush
+// client SDK, which authenticate with a deployment key instead of a JWT
+type AcquisitionService struct {
+	db             database.Database
+	store          storage.BlobStore
+	diffService    *DiffService
+	metricsService *MetricsService
+	urlExpiry      time.Duration
+}
+
+func NewAcquisitionService(db database.Database, store storage.BlobStore, cfg *config.Config) *AcquisitionService {
+	return &AcquisitionService{
+		db:             db,
+		store:          store,
+		diffService:    NewDiffService(db, store),
+		metricsService: NewMetricsService(db),
+		urlExpiry:      time.Duration(cfg.SignedURLExpiry) * time.Minute,
+	}
+}
+
+// UpdateCheckParams is the state reported by a client when checking for updates
+type UpdateCheckParams struct {
+	DeploymentKey  string
+	AppVersion     string
+	PackageHash    string
+	ClientUniqueID string
+	IsCompanion    bool
+}
+
+// UpdateInfo describes the update a client should install, if any
+type UpdateInfo struct {
+	IsAvailable            bool
+	IsMandatory            bool
+	AppVersion             string // target binary version of the release
+	PackageHash            string
+	Label                  string
+	PackageSize            int64
+	Description            string
+	DownloadURL            string
+	UpdateAppVersion       bool
+	ShouldRunBinaryVersion bool // the client's current package was disabled, e.g. by a rollback
+}
+
+// inRollout deterministically buckets a client into 100 buckets by hashing
+// its unique ID with the release label, so a device stays in or out of a
+// staged rollout across checks and is only added as the rollout grows
+func inRollout(clientUniqueID string, release *models.Release) bool {
+	if release.Rollout >= 100 {
+		return true
+	}
+	if clientUniqueID == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(clientUniqueID + ":" + release.Label))
+	bucket := binary.BigEndian.Uint32(sum[:4]) % 100
+	return int(bucket) < release.Rollout
+}
+
+// UpdateCheck resolves the deployment by key and returns the newest enabled
+// release whose target binary range matches the client's app version. The
+// update is mandatory if any release between the client's current package
+// and the returned one is mandatory.
+func (s *AcquisitionService) UpdateCheck(params UpdateCheckParams) (*UpdateInfo, error) {
+	deployment, err := s.db.FindDeploymentByKey(params.DeploymentKey)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+
+	appVersion, err := utils.ParseVersion(params.AppVersion)
+	if err != nil && !params.IsCompanion {
+		return nil, ErrInvalidAppVersion
+	}
+
+	releases, err := s.db.FindReleasesByDeploymentID(deployment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	noUpdate := &UpdateInfo{AppVersion: params.AppVersion}
+
+	// Releases are ordered newest first. Scanning stops at the client's
+	// current package, since older releases can no longer be skipped.
+	var latestEnabled, release *models.Release
+	isMandatory, currentDisabled := false, false
+	for _, candidate := range releases {
+		isCurrent := params.PackageHash != "" && candidate.PackageHash == params.PackageHash
+
+		if !candidate.IsDisabled {
+			if latestEnabled == nil {
+				latestEnabled = candidate
+			}
+			if s.isCompatible(params, appVersion, candidate) && (isCurrent || inRollout(params.ClientUniqueID, candidate)) {
+				if release == nil {
+					release = candidate
+				}
+				if candidate.IsMandatory && !isCurrent {
+					isMandatory = true
+				}
+			}
+		}
+
+		if isCurrent {
+			currentDisabled = candidate.IsDisabled
+			break
+		}
+	}
+
+	if release == nil {
+		// Clients whose package was pulled with nothing to replace it go
+		// back to the package shipped in the binary
+		noUpdate.ShouldRunBinaryVersion = currentDisabled
+
+		if latestEnabled == nil {
+			return noUpdate, nil
+		}
+
+		// Tell clients running an older binary that a newer one is required
+		// for the latest release. Compatible clients left out by a staged
+		// rollout keep their binary.
+		targetRange, err := utils.ParseRange(latestEnabled.AppVersion)
+		if err == nil && !params.IsCompanion && !targetRange.Satisfies(appVersion) && targetRange.IsAbove(appVersion) {
+			noUpdate.UpdateAppVersion = true
+			noUpdate.AppVersion = latestEnabled.AppVersion
+		}
+		return noUpdate, nil
+	}
+
+	if release.PackageHash == params.PackageHash {
+		return noUpdate, nil
+	}
+
+	// Serve a diff against the client's current package when one is cached
+	blobPath, packageSize := release.BlobPath, release.Size
+	if diff := s.diffService.FindDiff(deployment.AppID, params.PackageHash, release); diff != nil {
+		blobPath, packageSize = diff.BlobPath, diff.Size
+	}
+
+	downloadURL, err := s.store.SignedURL(blobPath, s.urlExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateInfo{
+		IsAvailable: true,
+		IsMandatory: isMandatory,
+		AppVersion:  release.AppVersion,
+		PackageHash: release.PackageHash,
+		Label:       release.Label,
+		PackageSize: packageSize,
+		Description: release.Description,
+		DownloadURL: downloadURL,
+	}, nil
+}
+
+// isCompatible reports whether a release targets the client's binary version.
+// Companion apps (such as the CodePush test harness) accept any release.
+func (s *AcquisitionService) isCompatible(params UpdateCheckParams, appVersion utils.Version, release *models.Release) bool {
+	if params.IsCompanion {
+		return true
+	}
+
+	targetRange, err := utils.ParseRange(release.AppVersion)
+	if err != nil {
+		return false
+	}
+	return targetRange.Satisfies(appVersion)
+}
+
+// DeployReportParams is the install result reported by a client
+type DeployReportParams struct {
+	DeploymentKey             string
+	Label                     string
+	AppVersion                string
+	PreviousDeploymentKey     string
+	PreviousLabelOrAppVersion string
+	Status                    string
+	ClientUniqueID            string
+}
+
+// DownloadReportParams is reported by a client after downloading a package
+type DownloadReportParams struct {
+	DeploymentKey  string
+	Label          string
+	ClientUniqueID string
+}
+
+// resolveRelease finds the deployment for a key and, when a label is given,
+// the release it refers to
+func (s *AcquisitionService) resolveRelease(deploymentKey, label string) (*models.Deployment, *models.Release, error) {
+	deployment, err := s.db.FindDeploymentByKey(deploymentKey)
+	if err != nil {
+		return nil, nil, utils.ErrNotFound
+	}
+
+	if label == "" {
+		return deployment, nil, nil
+	}
+
+	release, err := s.db.FindReleaseByLabel(deployment.ID, label)
+	if err != nil {
+		return nil, nil, utils.ErrNotFound
+	}
+
+	return deployment, release, nil
+}
+
+// ReportDeploy records the outcome of installing a release, or of running the
+// binary version when no label is given
+func (s *AcquisitionService) ReportDeploy(params DeployReportParams) error {
+	switch params.Status {
+	case "", models.StatusDeploymentSucceeded, models.StatusDeploymentFailed:
+	default:
+		return ErrInvalidStatus
+	}
+
+	deployment, release, err := s.resolveRelease(params.DeploymentKey, params.Label)
+	if err != nil {
+		return err
+	}
+
+	report := &models.StatusReport{
+		DeploymentID:              deployment.ID,
+		Kind:                      models.StatusReportDeploy,
+		Label:                     params.Label,
+		AppVersion:                params.AppVersion,
+		PreviousDeploymentKey:     params.PreviousDeploymentKey,
+		PreviousLabelOrAppVersion: params.PreviousLabelOrAppVersion,
+		Status:                    params.Status,
+		ClientUniqueID:            params.ClientUniqueID,
+	}
+	if release != nil {
+		report.ReleaseID = &release.ID
+	}
+
+	if err := s.db.CreateStatusReport(report); err != nil {
+		return err
+	}
+
+	return s.metricsService.RecordDeploy(deployment, release, params)
+}
+
+// ReportDownload records that a client downloaded a release
+func (s *AcquisitionService) ReportDownload(params DownloadReportParams) error {
+	deployment, release, err := s.resolveRelease(params.DeploymentKey, params.Label)
+	if err != nil {
+		return err
+	}
+	if release == nil {
+		return utils.ErrNotFound
+	}
+
+	if err := s.db.CreateStatusReport(&models.StatusReport{
+		DeploymentID:   deployment.ID,
+		ReleaseID:      &release.ID,
+		Kind:           models.StatusReportDownload,
+		Label:          release.Label,
+		ClientUniqueID: params.ClientUniqueID,
+	}); err != nil {
+		return err
+	}
+
+	return s.metricsService.RecordDownload(deployment, release)
+}
@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"gorm.io/gorm"
+)
+
+// acquisitionDB serves one deployment and its releases, newest first
+type acquisitionDB struct {
+	database.Database
+	releases []*models.Release
+}
+
+func (d *acquisitionDB) FindDeploymentByKey(key string) (*models.Deployment, error) {
+	return &models.Deployment{ID: 1, Key: key}, nil
+}
+
+func (d *acquisitionDB) FindReleasesByDeploymentID(deploymentID uint) ([]*models.Release, error) {
+	return d.releases, nil
+}
+
+// No diffs are cached and none can be built
+func (d *acquisitionDB) FindPackageDiff(fromPackageHash, toBlobPath string) (*models.PackageDiff, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *acquisitionDB) FindReleaseByPackageHash(appID, packageHash string) (*models.Release, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+type urlStore struct{}
+
+func (urlStore) Put(key string, r io.Reader, size int64) error { return nil }
+func (urlStore) Get(key string) (io.ReadCloser, error)         { return nil, storage.ErrNotFound }
+func (urlStore) Delete(key string) error                       { return nil }
+func (urlStore) Stat(key string) (*storage.BlobInfo, error)    { return nil, storage.ErrNotFound }
+func (urlStore) SignedURL(key string, expiry time.Duration) (string, error) {
+	return "https://blobs.example.com/" + key, nil
+}
+
+func TestUpdateCheck(t *testing.T) {
+	release := func(label, appVersion string, rollout int) *models.Release {
+		return &models.Release{
+			Label:       label,
+			AppVersion:  appVersion,
+			PackageHash: "hash-" + label,
+			BlobPath:    "packages/" + label + ".zip",
+			Rollout:     rollout,
+		}
+	}
+	mandatory := func(r *models.Release) *models.Release {
+		r.IsMandatory = true
+		return r
+	}
+	disabled := func(r *models.Release) *models.Release {
+		r.IsDisabled = true
+		return r
+	}
+
+	tests := []struct {
+		name             string
+		releases         []*models.Release
+		appVersion       string
+		clientUniqueID   string
+		packageHash      string
+		wantAvailable    bool
+		wantLabel        string
+		wantMandatory    bool
+		wantUpdateBinary bool
+		wantRunBinary    bool
+		wantAppVersion   string
+	}{
+		{
+			name:          "compatible release",
+			releases:      []*models.Release{release("v1", "1.2.x", 100)},
+			appVersion:    "1.2.0",
+			wantAvailable: true,
+			wantLabel:     "v1",
+		},
+		{
+			name:           "compatible client left out of a staged rollout",
+			releases:       []*models.Release{release("v1", "1.2.x", 50)},
+			appVersion:     "1.2.0",
+			wantAppVersion: "1.2.0",
+		},
+		{
+			name:           "compatible client without a unique ID during a staged rollout",
+			releases:       []*models.Release{release("v1", "^1.0.0", 99)},
+			appVersion:     "1.5.0",
+			clientUniqueID: "",
+			wantAppVersion: "1.5.0",
+		},
+		{
+			name:             "binary older than the latest release",
+			releases:         []*models.Release{release("v1", "2.0.0", 100)},
+			appVersion:       "1.0.0",
+			wantUpdateBinary: true,
+			wantAppVersion:   "2.0.0",
+		},
+		{
+			name:           "binary newer than the latest release",
+			releases:       []*models.Release{release("v1", "1.0.0", 100)},
+			appVersion:     "2.0.0",
+			wantAppVersion: "2.0.0",
+		},
+		{
+			name:           "binary between the alternatives of the target range",
+			releases:       []*models.Release{release("v1", "^1.0.0 || ^3.0.0", 100)},
+			appVersion:     "2.0.0",
+			wantAppVersion: "2.0.0",
+		},
+		{
+			name:          "older compatible release behind an incompatible one",
+			releases:      []*models.Release{release("v2", "2.0.0", 100), release("v1", "1.0.0", 100)},
+			appVersion:    "1.0.0",
+			wantAvailable: true,
+			wantLabel:     "v1",
+		},
+		{
+			name: "mandatory release between the current and the latest",
+			releases: []*models.Release{
+				release("v3", "1.x", 100), mandatory(release("v2", "1.x", 100)), release("v1", "1.x", 100),
+			},
+			appVersion:    "1.0.0",
+			packageHash:   "hash-v1",
+			wantAvailable: true,
+			wantLabel:     "v3",
+			wantMandatory: true,
+		},
+		{
+			name: "mandatory release older than the current one",
+			releases: []*models.Release{
+				release("v3", "1.x", 100), release("v2", "1.x", 100), mandatory(release("v1", "1.x", 100)),
+			},
+			appVersion:    "1.0.0",
+			packageHash:   "hash-v2",
+			wantAvailable: true,
+			wantLabel:     "v3",
+		},
+		{
+			name: "mandatory current release",
+			releases: []*models.Release{
+				release("v2", "1.x", 100), mandatory(release("v1", "1.x", 100)),
+			},
+			appVersion:    "1.0.0",
+			packageHash:   "hash-v1",
+			wantAvailable: true,
+			wantLabel:     "v2",
+		},
+		{
+			name: "mandatory latest release without a current package",
+			releases: []*models.Release{
+				mandatory(release("v2", "1.x", 100)), release("v1", "1.x", 100),
+			},
+			appVersion:    "1.0.0",
+			wantAvailable: true,
+			wantLabel:     "v2",
+			wantMandatory: true,
+		},
+		{
+			name:           "client already on the latest release",
+			releases:       []*models.Release{release("v2", "1.x", 100), mandatory(release("v1", "1.x", 100))},
+			appVersion:     "1.0.0",
+			packageHash:    "hash-v2",
+			wantAppVersion: "1.0.0",
+		},
+		{
+			name:           "current release disabled without a replacement",
+			releases:       []*models.Release{disabled(release("v2", "1.x", 100)), release("v1", "1.x", 100)},
+			appVersion:     "1.0.0",
+			packageHash:    "hash-v2",
+			wantRunBinary:  true,
+			wantAppVersion: "1.0.0",
+		},
+		{
+			name:          "current release disabled behind a newer one",
+			releases:      []*models.Release{release("v3", "1.x", 100), disabled(release("v2", "1.x", 100))},
+			appVersion:    "1.0.0",
+			packageHash:   "hash-v2",
+			wantAvailable: true,
+			wantLabel:     "v3",
+		},
+		{
+			name:           "client without a package while the latest release is disabled",
+			releases:       []*models.Release{disabled(release("v1", "1.x", 100))},
+			appVersion:     "1.0.0",
+			wantAppVersion: "1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &acquisitionDB{releases: tt.releases}
+			s := &AcquisitionService{
+				db:          db,
+				store:       urlStore{},
+				diffService: NewDiffService(db, urlStore{}),
+				urlExpiry:   time.Minute,
+			}
+
+			info, err := s.UpdateCheck(UpdateCheckParams{
+				DeploymentKey:  "key",
+				AppVersion:     tt.appVersion,
+				ClientUniqueID: tt.clientUniqueID,
+				PackageHash:    tt.packageHash,
+			})
+			if err != nil {
+				t.Fatalf("UpdateCheck returned error: %v", err)
+			}
+			if info.IsAvailable != tt.wantAvailable {
+				t.Fatalf("IsAvailable = %v, want %v", info.IsAvailable, tt.wantAvailable)
+			}
+			if tt.wantAvailable {
+				if info.Label != tt.wantLabel {
+					t.Errorf("Label = %q, want %q", info.Label, tt.wantLabel)
+				}
+				if info.IsMandatory != tt.wantMandatory {
+					t.Errorf("IsMandatory = %v, want %v", info.IsMandatory, tt.wantMandatory)
+				}
+				return
+			}
+			if info.UpdateAppVersion != tt.wantUpdateBinary {
+				t.Errorf("UpdateAppVersion = %v, want %v", info.UpdateAppVersion, tt.wantUpdateBinary)
+			}
+			if info.ShouldRunBinaryVersion != tt.wantRunBinary {
+				t.Errorf("ShouldRunBinaryVersion = %v, want %v", info.ShouldRunBinaryVersion, tt.wantRunBinary)
+			}
+			if info.AppVersion != tt.wantAppVersion {
+				t.Errorf("AppVersion = %q, want %q", info.AppVersion, tt.wantAppVersion)
+			}
+		})
+	}
+}
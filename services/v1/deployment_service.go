@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+type DeploymentService struct {
+	db database.Database
+}
+
+func NewDeploymentService(db database.Database) *DeploymentService {
+	return &DeploymentService{db: db}
+}
+
+// authorizeApp loads an app and checks that it belongs to the given user
+func authorizeApp(db database.Database, userID uint, appID string) (*models.App, error) {
+	app, err := db.FindAppByID(appID)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+
+	if app.UserID != userID {
+		return nil, utils.ErrAccessDenied
+	}
+
+	return app, nil
+}
+
+// authorizeDeployment returns a deployment of an app the user owns or, for
+// service accounts, has been granted
+func authorizeDeployment(db database.Database, userID uint, appID, deploymentName string) (*models.App, *models.Deployment, error) {
+	app, err := db.FindAppByID(appID)
+	if err != nil {
+		return nil, nil, utils.ErrNotFound
+	}
+
+	if app.UserID != userID {
+		if err := authorizeServiceAccount(db, userID, app, deploymentName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	deployment, err := db.FindDeploymentByName(app.ID, deploymentName)
+	if err != nil {
+		return nil, nil, utils.ErrNotFound
+	}
+
+	return app, deployment, nil
+}
+
+// generateDeploymentKey returns a new random key used by clients to identify a deployment
+func generateDeploymentKey() string {
+	return generateRandomString(20)
+}
+
+func (s *DeploymentService) CreateDeployment(userID uint, appID, name string) (*models.Deployment, error) {
+	app, err := authorizeApp(s.db, userID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, _ := s.db.FindDeploymentByName(app.ID, name); existing != nil {
+		return nil, utils.ErrAlreadyExists
+	}
+
+	deployment := &models.Deployment{
+		AppID: app.ID,
+		Name:  name,
+		Key:   generateDeploymentKey(),
+	}
+
+	if err := s.db.CreateDeployment(deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+func (s *DeploymentService) GetDeployments(userID uint, appID string) ([]*models.Deployment, error) {
+	app, err := authorizeApp(s.db, userID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.FindDeploymentsByAppID(app.ID)
+}
+
+func (s *DeploymentService) GetDeployment(userID uint, appID, name string) (*models.Deployment, error) {
+	app, err := authorizeApp(s.db, userID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := s.db.FindDeploymentByName(app.ID, name)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+
+	return deployment, nil
+}
+
+// UpdateDeployment renames a deployment, rotates its key and/or replaces its
+// signing key. A nil signing key leaves it unchanged; an empty one falls back
+// to the app's key.
+func (s *DeploymentService) UpdateDeployment(userID uint, appID, name, newName string, regenerateKey bool, signingPublicKey *string) (*models.Deployment, error) {
+	deployment, err := s.GetDeployment(userID, appID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if newName != "" && newName != deployment.Name {
+		if existing, _ := s.db.FindDeploymentByName(appID, newName); existing != nil {
+			return nil, utils.ErrAlreadyExists
+		}
+		deployment.Name = newName
+	}
+	if regenerateKey {
+		deployment.Key = generateDeploymentKey()
+	}
+	if signingPublicKey != nil {
+		if err := validateSigningKey(*signingPublicKey); err != nil {
+			return nil, err
+		}
+		deployment.SigningPublicKey = *signingPublicKey
+	}
+
+	if err := s.db.UpdateDeployment(deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+func (s *DeploymentService) DeleteDeployment(userID uint, appID, name string) error {
+	deployment, err := s.GetDeployment(userID, appID, name)
+	if err != nil {
+		return err
+	}
+
+	return s.db.DeleteDeployment(deployment.ID)
+}
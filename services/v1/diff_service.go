@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+// diffManifestName is the file in a diff package listing deleted files, as
+// expected by the CodePush SDK
+const diffManifestName = "hotcodepush.json"
+
+// diffsInProgress tracks diffs being generated so concurrent update checks
+// from many clients only build each diff once
+var diffsInProgress sync.Map
+
+// DiffService builds and caches differential packages between releases
+type DiffService struct {
+	db    database.Database
+	store storage.BlobStore
+}
+
+func NewDiffService(db database.Database, store storage.BlobStore) *DiffService {
+	return &DiffService{db: db, store: store}
+}
+
+// diffBlobPath names diffs after the target blob rather than its hash, since
+// signed releases of the same content are stored as separate packages
+func diffBlobPath(fromPackageHash, toBlobPath string) string {
+	return "diffs/" + fromPackageHash + "_" + strings.TrimSuffix(path.Base(toBlobPath), ".zip") + ".zip"
+}
+
+// FindDiff returns the cached diff between two packages of an app. If it does
+// not exist yet, generation is started in the background and nil is returned
+// so the caller can fall back to the full package.
+func (s *DiffService) FindDiff(appID, fromPackageHash string, to *models.Release) *models.PackageDiff {
+	if fromPackageHash == "" || fromPackageHash == to.PackageHash {
+		return nil
+	}
+
+	if diff, err := s.db.FindPackageDiff(fromPackageHash, to.BlobPath); err == nil {
+		// A diff is only worth serving when it is smaller than the full package
+		if diff.Size >= to.Size {
+			return nil
+		}
+		return diff
+	}
+
+	// Diffs can only be built from packages this server has stored for the
+	// app, as the hash is sent by unauthenticated clients
+	from, err := s.db.FindReleaseByPackageHash(appID, fromPackageHash)
+	if err != nil {
+		return nil
+	}
+
+	key := diffBlobPath(fromPackageHash, to.BlobPath)
+	if _, running := diffsInProgress.LoadOrStore(key, true); !running {
+		go func() {
+			defer diffsInProgress.Delete(key)
+			if _, err := s.GenerateDiff(from, to); err != nil {
+				log.Printf("Failed to generate diff %s: %v", key, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// GenerateDiff builds the diff between the packages of two releases, stores it
+// and records it in the database
+func (s *DiffService) GenerateDiff(from, to *models.Release) (*models.PackageDiff, error) {
+	fromFile, err := s.download(from.BlobPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(fromFile.Name())
+	defer fromFile.Close()
+
+	toFile, err := s.download(to.BlobPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(toFile.Name())
+	defer toFile.Close()
+
+	fromZip, err := openZip(fromFile)
+	if err != nil {
+		return nil, err
+	}
+	toZip, err := openZip(toFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fromManifest, err := utils.PackageManifest(fromZip)
+	if err != nil {
+		return nil, err
+	}
+	toManifest, err := utils.PackageManifest(toZip)
+	if err != nil {
+		return nil, err
+	}
+
+	diffFile, err := os.CreateTemp("", "codepush-diff-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(diffFile.Name())
+	defer diffFile.Close()
+
+	writer := zip.NewWriter(diffFile)
+	for _, file := range toZip.File {
+		// Clients verify the signature of the package they end up with, so
+		// it is always included
+		hash, ok := toManifest[file.Name]
+		changed := ok && fromManifest[file.Name] != hash
+		if !changed && !utils.IsSignatureFile(file.Name) {
+			continue
+		}
+		if err := writer.Copy(file); err != nil {
+			return nil, err
+		}
+	}
+
+	deletedFiles := []string{}
+	for name := range fromManifest {
+		if _, ok := toManifest[name]; !ok {
+			deletedFiles = append(deletedFiles, name)
+		}
+	}
+	sort.Strings(deletedFiles)
+
+	manifest, err := writer.Create(diffManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(manifest).Encode(map[string][]string{"deletedFiles": deletedFiles}); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	size, err := diffFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := diffFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	diff := &models.PackageDiff{
+		FromPackageHash: from.PackageHash,
+		ToPackageHash:   to.PackageHash,
+		ToBlobPath:      to.BlobPath,
+		BlobPath:        diffBlobPath(from.PackageHash, to.BlobPath),
+		Size:            size,
+	}
+	if err := s.store.Put(diff.BlobPath, diffFile, size); err != nil {
+		return nil, err
+	}
+	if err := s.db.CreatePackageDiff(diff); err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// download copies a blob into a temporary file
+func (s *DiffService) download(key string) (*os.File, error) {
+	blob, err := s.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	tmp, err := os.CreateTemp("", "codepush-package-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmp, blob); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+func openZip(f *os.File) (*zip.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(f, info.Size())
+}
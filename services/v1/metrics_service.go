@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+)
+
+// MetricsService turns client status reports into per-label counters
+type MetricsService struct {
+	db database.Database
+}
+
+func NewMetricsService(db database.Database) *MetricsService {
+	return &MetricsService{db: db}
+}
+
+// RecordDeploy updates the counters for an install report. A successful
+// install moves the device's active count from its previous label (or binary
+// version) to the new one; a report without a label means the device is
+// running the binary version.
+func (s *MetricsService) RecordDeploy(deployment *models.Deployment, release *models.Release, params DeployReportParams) error {
+	current := params.AppVersion
+	if release != nil {
+		current = release.Label
+	}
+
+	if params.Status == models.StatusDeploymentFailed {
+		return s.db.IncrementReleaseMetric(deployment.ID, current, models.MetricFailed, 1)
+	}
+
+	if release != nil {
+		if err := s.db.IncrementReleaseMetric(deployment.ID, current, models.MetricInstalled, 1); err != nil {
+			return err
+		}
+	}
+	if err := s.db.IncrementReleaseMetric(deployment.ID, current, models.MetricActive, 1); err != nil {
+		return err
+	}
+
+	if params.PreviousLabelOrAppVersion == "" {
+		return nil
+	}
+
+	// The previous label may belong to another deployment of the app, e.g.
+	// when a tester switches a device from Staging to Production. Reports are
+	// unauthenticated, so deployments of other apps are left alone.
+	previous := deployment
+	if params.PreviousDeploymentKey != "" && params.PreviousDeploymentKey != deployment.Key {
+		var err error
+		previous, err = s.db.FindDeploymentByKey(params.PreviousDeploymentKey)
+		if err != nil || previous.AppID != deployment.AppID {
+			return nil
+		}
+	}
+
+	if err := s.db.IncrementReleaseMetric(previous.ID, params.PreviousLabelOrAppVersion, models.MetricActive, -1); err != nil {
+		return err
+	}
+
+	// Devices moved off a label by a server side rollback count as rollbacks of it
+	if release != nil && release.ReleaseMethod == models.ReleaseMethodRollback {
+		return s.db.IncrementReleaseMetric(previous.ID, params.PreviousLabelOrAppVersion, models.MetricRollbacks, 1)
+	}
+	return nil
+}
+
+// RecordDownload counts a package download
+func (s *MetricsService) RecordDownload(deployment *models.Deployment, release *models.Release) error {
+	return s.db.IncrementReleaseMetric(deployment.ID, release.Label, models.MetricDownloaded, 1)
+}
+
+// GetMetrics returns the counters of a deployment keyed by label
+func (s *MetricsService) GetMetrics(userID uint, appID, deploymentName string) (map[string]*models.ReleaseMetric, error) {
+	_, deployment, err := authorizeDeployment(s.db, userID, appID, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := s.db.FindReleaseMetrics(deployment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byLabel := make(map[string]*models.ReleaseMetric, len(metrics))
+	for _, metric := range metrics {
+		byLabel[metric.Label] = metric
+	}
+	return byLabel, nil
+}
@@ -0,0 +1,386 @@
+package v1
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	"gorm.io/gorm"
+)
+
+// oidcDB keeps the users, identities and sign in attempts of OIDC tests in
+// memory
+type oidcDB struct {
+	database.Database
+	users      []*models.User
+	identities []*models.UserIdentity
+	states     map[string]*models.OIDCLoginState
+}
+
+func (d *oidcDB) CreateOIDCLoginState(state *models.OIDCLoginState) error {
+	d.states[state.State] = state
+	return nil
+}
+
+func (d *oidcDB) ConsumeOIDCLoginState(value string) (*models.OIDCLoginState, error) {
+	state, ok := d.states[value]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(d.states, value)
+	return state, nil
+}
+
+func (d *oidcDB) FindUserIdentity(provider, subject string) (*models.UserIdentity, error) {
+	for _, identity := range d.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *oidcDB) CreateUserIdentity(identity *models.UserIdentity) error {
+	d.identities = append(d.identities, identity)
+	return nil
+}
+
+func (d *oidcDB) CreateUserWithIdentity(user *models.User, identity *models.UserIdentity) error {
+	user.ID = uint(len(d.users) + 1)
+	d.users = append(d.users, user)
+	identity.UserID = user.ID
+	return d.CreateUserIdentity(identity)
+}
+
+func (d *oidcDB) FindUserByID(id uint) (*models.User, error) {
+	for _, user := range d.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *oidcDB) FindUserByEmail(email string) (*models.User, error) {
+	for _, user := range d.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (d *oidcDB) UpdateUser(user *models.User) error                  { return nil }
+func (d *oidcDB) CreateSession(session *models.Session) error         { return nil }
+func (d *oidcDB) CreateRefreshToken(token *models.RefreshToken) error { return nil }
+
+// mockProvider is an OpenID Connect provider that signs in one user with the
+// code "valid-code"
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// Set from the authorization request the user was sent with
+	challenge string
+	nonce     string
+
+	// claims overrides the claims of the next ID token; nil values are
+	// removed
+	claims jwt.MapClaims
+	// signingKey signs the next ID token instead of the published key
+	signingKey *rsa.PrivateKey
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("grant_type") != "authorization_code" ||
+			r.PostFormValue("code") != "valid-code" ||
+			r.PostFormValue("client_id") != "client-id" ||
+			r.PostFormValue("client_secret") != "client-secret" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-token",
+			"id_token":     p.idToken(t),
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) idToken(t *testing.T) string {
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            "client-id",
+		"sub":            "subject-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "jane",
+		"nonce":          p.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range p.claims {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	key := p.key
+	if p.signingKey != nil {
+		key = p.signingKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func newTestOIDCService(t *testing.T, db database.Database, provider *mockProvider) *OIDCService {
+	cfg := &config.Config{
+		ServerURL:     "https://codepush.example.com",
+		JWTAlgorithm:  "HS256",
+		JWTKey:        "test-secret",
+		JWTExpiry:     1,
+		RefreshExpiry: 1,
+		OIDCProviders: []config.OIDCProvider{{
+			Name:         "mock",
+			Issuer:       provider.server.URL,
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			Scopes:       []string{"openid", "email"},
+		}},
+	}
+	jwtService, err := services.NewJWTService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOIDCService(db, jwtService, cfg)
+}
+
+// signIn sends a user through the provider and back with a code
+func signIn(t *testing.T, s *OIDCService, provider *mockProvider, code string) (*LoginResult, error) {
+	authURL, err := s.StartLogin("mock", "")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := u.Query()
+	if got := u.Scheme + "://" + u.Host + u.Path; got != provider.server.URL+"/authorize" {
+		t.Fatalf("authorization URL = %s, want the discovered endpoint", got)
+	}
+	if params.Get("redirect_uri") != "https://codepush.example.com/api/v1/auth/oidc/mock/callback" {
+		t.Fatalf("redirect_uri = %q", params.Get("redirect_uri"))
+	}
+	if params.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q", params.Get("code_challenge_method"))
+	}
+	provider.challenge, provider.nonce = params.Get("code_challenge"), params.Get("nonce")
+
+	result, _, err := s.CompleteLogin("mock", code, params.Get("state"), ClientInfo{})
+	return result, err
+}
+
+func TestOIDCCompleteLogin(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		code       string
+		claims     jwt.MapClaims
+		signingKey *rsa.PrivateKey
+		wantErr    error
+	}{
+		{name: "valid sign in", code: "valid-code"},
+		{name: "wrong code", code: "wrong-code", wantErr: ErrProviderFailure},
+		{name: "token for another client", code: "valid-code", claims: jwt.MapClaims{"aud": "other-client"}, wantErr: ErrProviderFailure},
+		{name: "token from another issuer", code: "valid-code", claims: jwt.MapClaims{"iss": "https://evil.example.com"}, wantErr: ErrProviderFailure},
+		{name: "replayed nonce", code: "valid-code", claims: jwt.MapClaims{"nonce": "old-nonce"}, wantErr: ErrProviderFailure},
+		{name: "expired token", code: "valid-code", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}, wantErr: ErrProviderFailure},
+		{name: "token signed with another key", code: "valid-code", signingKey: otherKey, wantErr: ErrProviderFailure},
+		{name: "unverified email", code: "valid-code", claims: jwt.MapClaims{"email_verified": false}, wantErr: ErrEmailNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newMockProvider(t)
+			provider.claims, provider.signingKey = tt.claims, tt.signingKey
+			db := &oidcDB{states: map[string]*models.OIDCLoginState{}}
+			s := newTestOIDCService(t, db, provider)
+
+			result, err := signIn(t, s, provider, tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteLogin error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(db.users) != 0 {
+					t.Fatalf("created %d users for a failed sign in", len(db.users))
+				}
+				return
+			}
+
+			if result.Tokens == nil || result.Tokens.AccessToken == "" {
+				t.Fatal("no tokens issued")
+			}
+			if len(db.users) != 1 || db.users[0].Email != "jane@example.com" || !db.users[0].EmailVerified {
+				t.Fatalf("users = %+v, want one verified user for the email", db.users)
+			}
+		})
+	}
+}
+
+func TestOIDCLinksAccounts(t *testing.T) {
+	provider := newMockProvider(t)
+	existing := &models.User{ID: 7, Email: "jane@example.com", Username: "jane", EmailVerified: true}
+	db := &oidcDB{
+		users:  []*models.User{existing},
+		states: map[string]*models.OIDCLoginState{},
+	}
+	s := newTestOIDCService(t, db, provider)
+
+	result, err := signIn(t, s, provider, "valid-code")
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if result.User.ID != existing.ID {
+		t.Fatalf("signed in user %d, want the existing user %d", result.User.ID, existing.ID)
+	}
+	if len(db.identities) != 1 || db.identities[0].UserID != existing.ID || db.identities[0].Subject != "subject-1" {
+		t.Fatalf("identities = %+v, want subject-1 linked to the existing user", db.identities)
+	}
+
+	// Later sign ins find the user by subject, even after the email changes
+	provider.claims = jwt.MapClaims{"email": "jane@new.example.com"}
+	result, err = signIn(t, s, provider, "valid-code")
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+	if result.User.ID != existing.ID || len(db.users) != 1 || len(db.identities) != 1 {
+		t.Fatalf("second sign in signed in user %d with %d users, %d identities", result.User.ID, len(db.users), len(db.identities))
+	}
+}
+
+// An account registered with someone else's address must not be taken over
+// by, or handed to, the address's owner signing in through a provider
+func TestOIDCDoesNotLinkUnverifiedAccounts(t *testing.T) {
+	provider := newMockProvider(t)
+	squatter := &models.User{ID: 7, Email: "jane@example.com", Username: "squatter", Password: "known-hash"}
+	db := &oidcDB{
+		users:  []*models.User{squatter},
+		states: map[string]*models.OIDCLoginState{},
+	}
+	s := newTestOIDCService(t, db, provider)
+
+	if _, err := signIn(t, s, provider, "valid-code"); err != ErrAccountNotVerified {
+		t.Fatalf("CompleteLogin error = %v, want %v", err, ErrAccountNotVerified)
+	}
+	if len(db.identities) != 0 {
+		t.Fatalf("identities = %+v, want none linked", db.identities)
+	}
+	if squatter.EmailVerified || squatter.Password != "known-hash" {
+		t.Fatalf("unverified account was changed: %+v", squatter)
+	}
+}
+
+func TestOIDCRejectsUnknownState(t *testing.T) {
+	provider := newMockProvider(t)
+	s := newTestOIDCService(t, &oidcDB{states: map[string]*models.OIDCLoginState{}}, provider)
+
+	if _, _, err := s.CompleteLogin("mock", "valid-code", "forged-state", ClientInfo{}); err != ErrInvalidLoginState {
+		t.Fatalf("CompleteLogin error = %v, want %v", err, ErrInvalidLoginState)
+	}
+}
+
+func TestRedirectAllowed(t *testing.T) {
+	s := &OIDCService{allowedRedirects: []string{
+		"https://app.example.com",
+		"https://admin.example.com/auth/",
+		"myapp://callback",
+	}}
+
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"", true},
+		{"https://app.example.com", true},
+		{"https://app.example.com/", true},
+		{"https://app.example.com/login/done?x=1", true},
+		{"https://APP.example.com/", true},
+		{"https://app.example.com.evil.net/", false},
+		{"https://app.example.com@evil.net/", false},
+		{"https://user@app.example.com/", false},
+		{"http://app.example.com/", false},
+		{"https://app.example.com:8443/", false},
+		{"https://admin.example.com/auth", true},
+		{"https://admin.example.com/auth/done", true},
+		{"https://admin.example.com/authx", false},
+		{"https://admin.example.com/auth/../steal", false},
+		{"https://admin.example.com/", false},
+		{"myapp://callback", true},
+		{"myapp://callback.evil", false},
+		{"//app.example.com/", false},
+		{"javascript:alert(1)", false},
+	}
+	for _, tt := range tests {
+		if got := s.redirectAllowed(tt.uri); got != tt.want {
+			t.Errorf("redirectAllowed(%q) = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package v1
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/piyushsharma67/codepushserver/database"
 	"github.com/piyushsharma67/codepushserver/models"
 	"github.com/piyushsharma67/codepushserver/utils"
 )
 
+var (
+	ErrInvitationAnswered     = errors.New("invitation has already been accepted or declined")
+	ErrInviteEmailNotVerified = errors.New("email address must be verified to answer invitations")
+)
+
 type OrganizationService struct {
 	db database.Database
 }
@@ -15,6 +23,32 @@ func NewOrganizationService(db database.Database) *OrganizationService {
 	return &OrganizationService{db: db}
 }
 
+// findMember returns a user's membership of an organization. Members without
+// two-factor authentication are refused if the organization requires it,
+// except service accounts, which cannot sign in.
+func (s *OrganizationService) findMember(orgID uuid.UUID, userID uint) (*models.OrganizationMember, error) {
+	member, err := s.db.FindOrganizationMember(orgID, userID)
+	if err != nil {
+		return nil, utils.ErrAccessDenied
+	}
+
+	org, err := s.db.FindOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org.Require2FA {
+		user, err := s.db.FindUserByID(userID)
+		if err != nil {
+			return nil, utils.ErrAccessDenied
+		}
+		if !user.TOTPEnabled && !user.IsServiceAccount {
+			return nil, ErrTwoFactorRequired
+		}
+	}
+
+	return member, nil
+}
+
 type CreateOrganizationRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
@@ -25,10 +59,6 @@ type InviteUserRequest struct {
 	InviteeEmail   string `json:"invitee_email" binding:"required,email"`
 }
 
-type AcceptInviteRequest struct {
-	InviteID uint `json:"invite_id" binding:"required"`
-}
-
 type TransferAdminRequest struct {
 	OrganizationID uint `json:"organization_id" binding:"required"`
 	NewAdminID     uint `json:"new_admin_id" binding:"required"`
@@ -78,9 +108,9 @@ func (s *OrganizationService) GetOrganization(userID uint, orgID uuid.UUID) (*mo
 	}
 
 	// Check if user is a member
-	_, err = s.db.FindOrganizationMember(orgID, userID)
+	_, err = s.findMember(orgID, userID)
 	if err != nil {
-		return nil, utils.ErrAccessDenied
+		return nil, err
 	}
 
 	// Create a copy of the organization without the private token
@@ -106,21 +136,26 @@ func (s *OrganizationService) GetUserOrganizations(userID uint) ([]*models.Organ
 
 func (s *OrganizationService) InviteUser(userID uint, orgID uuid.UUID, email string, role string) error {
 	// Check if inviter is a member and has admin rights
-	member, err := s.db.FindOrganizationMember(orgID, userID)
+	member, err := s.findMember(orgID, userID)
 	if err != nil {
-		return utils.ErrAccessDenied
+		return err
 	}
 
 	if member.Role != "admin" {
 		return utils.ErrAccessDenied
 	}
 
+	// Reject roles the invitee could not be given on accepting
+	if err := (&models.OrganizationMember{Role: role}).ValidateRole(); err != nil {
+		return err
+	}
+
 	// Create invitation
 	invitation := &models.OrganizationInvitation{
 		OrganizationID: orgID,
 		Email:          email,
 		Role:           role,
-		Status:         "pending",
+		Status:         models.InvitationStatusPending,
 	}
 
 	return s.db.CreateOrganizationInvitation(invitation)
@@ -135,11 +170,88 @@ func (s *OrganizationService) GetPendingInvites(userID uint) ([]*models.Organiza
 	return s.db.FindPendingInvitationsByEmail(user.Email)
 }
 
+// findInvitation returns a pending invitation addressed to the user's
+// verified email. Invitations for other addresses are reported as missing.
+func (s *OrganizationService) findInvitation(userID, inviteID uint) (*models.User, *models.OrganizationInvitation, error) {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return nil, nil, utils.ErrNotFound
+	}
+	invitation, err := s.db.FindOrganizationInvitationByID(inviteID)
+	if err != nil || !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, nil, utils.ErrNotFound
+	}
+	if invitation.Status != models.InvitationStatusPending {
+		return nil, nil, ErrInvitationAnswered
+	}
+	// Anyone can register with an address they do not own
+	if !user.EmailVerified {
+		return nil, nil, ErrInviteEmailNotVerified
+	}
+	return user, invitation, nil
+}
+
+// AcceptInvite makes the user a member of the inviting organization with the
+// invited role. Organizations requiring two-factor authentication only accept
+// users who have enabled it.
+func (s *OrganizationService) AcceptInvite(userID, inviteID uint) (*models.OrganizationMember, error) {
+	user, invitation, err := s.findInvitation(userID, inviteID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.db.FindOrganizationByID(invitation.OrganizationID)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+	if org.Require2FA && !user.TOTPEnabled {
+		return nil, ErrTwoFactorRequired
+	}
+	if _, err := s.db.FindOrganizationMember(org.ID, user.ID); err == nil {
+		return nil, utils.ErrAlreadyExists
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         user.ID,
+		Role:           invitation.Role,
+	}
+	if err := member.ValidateRole(); err != nil {
+		return nil, err
+	}
+
+	accepted, err := s.db.AcceptOrganizationInvitation(invitation.ID, member)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvitationAnswered
+	}
+	return member, nil
+}
+
+// DeclineInvite turns down an invitation addressed to the user
+func (s *OrganizationService) DeclineInvite(userID, inviteID uint) error {
+	_, invitation, err := s.findInvitation(userID, inviteID)
+	if err != nil {
+		return err
+	}
+
+	declined, err := s.db.DeclineOrganizationInvitation(invitation.ID)
+	if err != nil {
+		return err
+	}
+	if !declined {
+		return ErrInvitationAnswered
+	}
+	return nil
+}
+
 func (s *OrganizationService) DeleteOrganization(userID uint, orgID uuid.UUID) error {
 	// Check if user is admin
-	member, err := s.db.FindOrganizationMember(orgID, userID)
+	member, err := s.findMember(orgID, userID)
 	if err != nil {
-		return utils.ErrAccessDenied
+		return err
 	}
 
 	if member.Role != "admin" {
@@ -149,11 +261,47 @@ func (s *OrganizationService) DeleteOrganization(userID uint, orgID uuid.UUID) e
 	return s.db.DeleteOrganization(orgID)
 }
 
+// SetRequire2FA sets whether members must use two-factor authentication. An
+// admin can only require it after enabling it themselves, so they cannot lock
+// themselves out.
+func (s *OrganizationService) SetRequire2FA(userID uint, orgID uuid.UUID, require bool) (*models.Organization, error) {
+	member, err := s.findMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Role != "admin" {
+		return nil, utils.ErrAccessDenied
+	}
+
+	if require {
+		user, err := s.db.FindUserByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !user.TOTPEnabled {
+			return nil, ErrTwoFactorRequired
+		}
+	}
+
+	org, err := s.db.FindOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	org.Require2FA = require
+	if err := s.db.UpdateOrganization(org); err != nil {
+		return nil, err
+	}
+
+	org.PrivateToken = ""
+	return org, nil
+}
+
 func (s *OrganizationService) TransferAdmin(userID uint, orgID uuid.UUID, newAdminID uint) error {
 	// Check if current user is admin
-	member, err := s.db.FindOrganizationMember(orgID, userID)
+	member, err := s.findMember(orgID, userID)
 	if err != nil {
-		return utils.ErrAccessDenied
+		return err
 	}
 
 	if member.Role != "admin" {
@@ -161,8 +309,13 @@ func (s *OrganizationService) TransferAdmin(userID uint, orgID uuid.UUID, newAdm
 	}
 
 	// Check if new admin is a member
-	newMember, err := s.db.FindOrganizationMember(orgID, newAdminID)
+	newMember, err := s.findMember(orgID, newAdminID)
 	if err != nil {
+		return err
+	}
+
+	// Service accounts cannot manage the organization
+	if newUser, err := s.db.FindUserByID(newAdminID); err != nil || newUser.IsServiceAccount {
 		return utils.ErrAccessDenied
 	}
 
@@ -0,0 +1,409 @@
+package v1
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/storage"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var (
+	ErrInvalidPackage     = errors.New("package must be a valid zip archive")
+	ErrInvalidAppVersion  = errors.New("app version must be a valid semver range")
+	ErrEmptyPackage       = errors.New("package does not contain any files")
+	ErrPackageUnchanged   = errors.New("package is identical to the current release")
+	ErrReleaseNotFound    = errors.New("release not found")
+	ErrInvalidRollout     = errors.New("rollout must be between 1 and 100")
+	ErrRolloutDecreased   = errors.New("rollout cannot be lower than the current rollout")
+	ErrSameDeployment     = errors.New("source and destination deployments must differ")
+	ErrNothingToRollback  = errors.New("deployment has no earlier release to roll back to")
+	ErrRollbackAppVersion = errors.New("cannot roll back to a release targeting a different app version")
+)
+
+type ReleaseService struct {
+	db    database.Database
+	store storage.BlobStore
+}
+
+func NewReleaseService(db database.Database, store storage.BlobStore) *ReleaseService {
+	return &ReleaseService{
+		db:    db,
+		store: store,
+	}
+}
+
+// ReleaseParams holds the metadata supplied alongside an uploaded package
+type ReleaseParams struct {
+	AppVersion  string
+	Description string
+	IsMandatory bool
+	Rollout     int    // percentage of clients, 0 means everyone
+	Signature   string // code signing JWT, if not embedded in the package
+}
+
+func validRollout(rollout int) bool {
+	return rollout >= 1 && rollout <= 100
+}
+
+// packageBlobPath returns the storage key of a full update package. Signed
+// packages embed their signature, so each signature gets its own blob.
+func packageBlobPath(packageHash, signature string) string {
+	if signature == "" {
+		return "packages/" + packageHash + ".zip"
+	}
+	sum := sha256.Sum256([]byte(signature))
+	return "packages/" + packageHash + "-" + hex.EncodeToString(sum[:8]) + ".zip"
+}
+
+func (s *ReleaseService) findAppDeployment(userID uint, appID, deploymentName string) (*models.App, *models.Deployment, error) {
+	return authorizeDeployment(s.db, userID, appID, deploymentName)
+}
+
+func (s *ReleaseService) findDeployment(userID uint, appID, deploymentName string) (*models.Deployment, error) {
+	_, deployment, err := s.findAppDeployment(userID, appID, deploymentName)
+	return deployment, err
+}
+
+// CreateRelease stores an uploaded zip package and records it as the next
+// release of the deployment
+func (s *ReleaseService) CreateRelease(userID uint, appID, deploymentName string, pkg io.Reader, params ReleaseParams) (*models.Release, error) {
+	if !utils.ValidRange(params.AppVersion) {
+		return nil, ErrInvalidAppVersion
+	}
+	if params.Rollout == 0 {
+		params.Rollout = 100
+	}
+	if !validRollout(params.Rollout) {
+		return nil, ErrInvalidRollout
+	}
+
+	app, deployment, err := s.findAppDeployment(userID, appID, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "codepush-upload-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return nil, ErrInvalidPackage
+	}
+	manifest, err := utils.PackageManifest(zr)
+	if err != nil {
+		return nil, ErrInvalidPackage
+	}
+	if len(manifest) == 0 {
+		return nil, ErrEmptyPackage
+	}
+	packageHash := utils.HashManifest(manifest)
+
+	if latest, _ := s.db.FindLatestRelease(deployment.ID); latest != nil && latest.PackageHash == packageHash {
+		return nil, ErrPackageUnchanged
+	}
+
+	embeddedSignature, err := utils.ReadPackageSignature(zr)
+	if err != nil {
+		return nil, ErrInvalidPackage
+	}
+	signature := params.Signature
+	if signature == "" {
+		signature = embeddedSignature
+	}
+	if err := verifyReleaseSignature(app, deployment, signature, packageHash); err != nil {
+		return nil, err
+	}
+
+	// Clients read the signature from the package, so embed it when it was
+	// uploaded separately
+	var blob io.ReadSeeker = tmp
+	if signature != embeddedSignature {
+		signed, err := os.CreateTemp("", "codepush-signed-*.zip")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(signed.Name())
+		defer signed.Close()
+
+		if err := utils.WriteSignedPackage(zr, signed, signature); err != nil {
+			return nil, err
+		}
+		if size, err = signed.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		blob = signed
+	}
+
+	// Packages are content addressed, so identical uploads share one blob
+	blobPath := packageBlobPath(packageHash, signature)
+	if _, err := s.store.Stat(blobPath); err == storage.ErrNotFound {
+		if _, err := blob.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := s.store.Put(blobPath, blob, size); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	release := &models.Release{
+		DeploymentID:  deployment.ID,
+		AppVersion:    params.AppVersion,
+		Description:   params.Description,
+		IsMandatory:   params.IsMandatory,
+		PackageHash:   packageHash,
+		BlobPath:      blobPath,
+		Size:          size,
+		Signature:     signature,
+		Rollout:       params.Rollout,
+		ReleaseMethod: models.ReleaseMethodUpload,
+		ReleasedBy:    userID,
+	}
+
+	if err := s.db.CreateRelease(release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// PromoteParams optionally overrides the metadata of a promoted release
+type PromoteParams struct {
+	Label       string // source release to promote, defaults to the latest
+	Description *string
+	IsMandatory *bool
+	Rollout     *int
+}
+
+// PromoteRelease copies a release of one deployment to another deployment of
+// the same app as a new label. The stored package is shared, not re-uploaded.
+func (s *ReleaseService) PromoteRelease(userID uint, appID, sourceName, destName string, params PromoteParams) (*models.Release, error) {
+	if sourceName == destName {
+		return nil, ErrSameDeployment
+	}
+
+	source, err := s.findDeployment(userID, appID, sourceName)
+	if err != nil {
+		return nil, err
+	}
+	app, dest, err := s.findAppDeployment(userID, appID, destName)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceRelease *models.Release
+	if params.Label != "" {
+		sourceRelease, err = s.db.FindReleaseByLabel(source.ID, params.Label)
+	} else {
+		sourceRelease, err = s.db.FindLatestRelease(source.ID)
+	}
+	if err != nil {
+		return nil, ErrReleaseNotFound
+	}
+
+	if latest, _ := s.db.FindLatestRelease(dest.ID); latest != nil && latest.PackageHash == sourceRelease.PackageHash {
+		return nil, ErrPackageUnchanged
+	}
+	if err := verifyReleaseSignature(app, dest, sourceRelease.Signature, sourceRelease.PackageHash); err != nil {
+		return nil, err
+	}
+
+	release := &models.Release{
+		DeploymentID:       dest.ID,
+		AppVersion:         sourceRelease.AppVersion,
+		Description:        sourceRelease.Description,
+		IsMandatory:        sourceRelease.IsMandatory,
+		PackageHash:        sourceRelease.PackageHash,
+		BlobPath:           sourceRelease.BlobPath,
+		Size:               sourceRelease.Size,
+		Signature:          sourceRelease.Signature,
+		Rollout:            100,
+		ReleaseMethod:      models.ReleaseMethodPromote,
+		OriginalLabel:      sourceRelease.Label,
+		OriginalDeployment: source.Name,
+		ReleasedBy:         userID,
+	}
+	if params.Description != nil {
+		release.Description = *params.Description
+	}
+	if params.IsMandatory != nil {
+		release.IsMandatory = *params.IsMandatory
+	}
+	if params.Rollout != nil {
+		if !validRollout(*params.Rollout) {
+			return nil, ErrInvalidRollout
+		}
+		release.Rollout = *params.Rollout
+	}
+
+	if err := s.db.CreateRelease(release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// RollbackRelease creates a new release of the deployment that re-points to
+// the package of an earlier release. Without a label it rolls back to the
+// release before the current one.
+func (s *ReleaseService) RollbackRelease(userID uint, appID, deploymentName, targetLabel string) (*models.Release, error) {
+	app, deployment, err := s.findAppDeployment(userID, appID, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := s.db.FindReleasesByDeploymentID(deployment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) < 2 {
+		return nil, ErrNothingToRollback
+	}
+	current := releases[0]
+
+	var target *models.Release
+	if targetLabel == "" {
+		target = releases[1]
+	} else {
+		for _, release := range releases[1:] {
+			if release.Label == targetLabel {
+				target = release
+				break
+			}
+		}
+		if target == nil {
+			return nil, ErrReleaseNotFound
+		}
+	}
+
+	if target.PackageHash == current.PackageHash {
+		return nil, ErrPackageUnchanged
+	}
+	if target.AppVersion != current.AppVersion {
+		return nil, ErrRollbackAppVersion
+	}
+	if err := verifyReleaseSignature(app, deployment, target.Signature, target.PackageHash); err != nil {
+		return nil, err
+	}
+
+	release := &models.Release{
+		DeploymentID:  deployment.ID,
+		AppVersion:    target.AppVersion,
+		Description:   target.Description,
+		IsMandatory:   target.IsMandatory,
+		PackageHash:   target.PackageHash,
+		BlobPath:      target.BlobPath,
+		Size:          target.Size,
+		Signature:     target.Signature,
+		Rollout:       100,
+		ReleaseMethod: models.ReleaseMethodRollback,
+		OriginalLabel: target.Label,
+		ReleasedBy:    userID,
+	}
+
+	if err := s.db.CreateRelease(release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// UpdateReleaseParams holds the editable fields of a release. Nil fields are
+// left unchanged.
+type UpdateReleaseParams struct {
+	Description *string
+	IsMandatory *bool
+	IsDisabled  *bool
+	Rollout     *int
+}
+
+// UpdateRelease changes the metadata of an existing release without
+// re-uploading its package. The rollout can only be raised so that clients
+// already in the rollout stay in it.
+func (s *ReleaseService) UpdateRelease(userID uint, appID, deploymentName, label string, params UpdateReleaseParams) (*models.Release, error) {
+	deployment, err := s.findDeployment(userID, appID, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.db.FindReleaseByLabel(deployment.ID, label)
+	if err != nil {
+		return nil, ErrReleaseNotFound
+	}
+
+	if params.Rollout != nil {
+		if !validRollout(*params.Rollout) {
+			return nil, ErrInvalidRollout
+		}
+		if *params.Rollout < release.Rollout {
+			return nil, ErrRolloutDecreased
+		}
+		release.Rollout = *params.Rollout
+	}
+	if params.Description != nil {
+		release.Description = *params.Description
+	}
+	if params.IsMandatory != nil {
+		release.IsMandatory = *params.IsMandatory
+	}
+	if params.IsDisabled != nil {
+		release.IsDisabled = *params.IsDisabled
+	}
+
+	if err := s.db.UpdateRelease(release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// ReleaseHistory is one page of a deployment's releases
+type ReleaseHistory struct {
+	Releases []*models.Release
+	Users    map[uint]*models.User // users who performed the releases, by ID
+	Total    int64
+}
+
+// GetHistory returns a page of the deployment's releases, newest first
+func (s *ReleaseService) GetHistory(userID uint, appID, deploymentName string, page, perPage int) (*ReleaseHistory, error) {
+	deployment, err := s.findDeployment(userID, appID, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, total, err := s.db.FindReleasesPage(deployment.ID, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[uint]*models.User)
+	for _, release := range releases {
+		if _, ok := users[release.ReleasedBy]; ok {
+			continue
+		}
+		if user, err := s.db.FindUserByID(release.ReleasedBy); err == nil {
+			users[release.ReleasedBy] = user
+		} else {
+			users[release.ReleasedBy] = nil
+		}
+	}
+
+	return &ReleaseHistory{Releases: releases, Users: users, Total: total}, nil
+}
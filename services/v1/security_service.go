@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed sign in attempts")
+
+const (
+	// failureWindow is how long failed attempts count towards throttling
+	failureWindow = 15 * time.Minute
+	// maxAccountFailures consecutive failures lock an account for
+	// accountLockout after the last one. Fewer failures add a delay that
+	// doubles with each one.
+	maxAccountFailures = 5
+	accountLockout     = 15 * time.Minute
+	// maxIPFailures failures from one IP address within failureWindow block
+	// it, whichever accounts they were for
+	maxIPFailures = 20
+
+	maxSecurityEvents     = 200
+	defaultSecurityEvents = 50
+)
+
+// SecurityService throttles sign in attempts and records them as login
+// events. Accounts are throttled by email, so unknown emails behave the same
+// as existing accounts.
+type SecurityService struct {
+	db database.Database
+}
+
+func NewSecurityService(db database.Database) *SecurityService {
+	return &SecurityService{db: db}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttledResults are the results counted towards throttling. Pending
+// attempts count until they finish, so parallel attempts throttle each other.
+var throttledResults = []string{models.LoginResultFailure, models.LoginResultPending}
+
+// StartLogin records a sign in attempt as pending before its credentials are
+// checked. The user is nil when no account has the email. The attempt is
+// passed to CheckLogin and finished with FinishLogin.
+func (s *SecurityService) StartLogin(user *models.User, email, ip, userAgent, method string) (*models.LoginEvent, error) {
+	event := newLoginEvent(user, email, ip, userAgent, method, models.LoginResultPending)
+	if err := s.db.CreateLoginEvent(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// CheckLogin returns ErrTooManyAttempts and how long to wait if an attempt
+// is refused because of earlier attempts for its account or IP address
+func (s *SecurityService) CheckLogin(attempt *models.LoginEvent) (time.Duration, error) {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	// Failures since the last successful sign in
+	results := append([]string{models.LoginResultSuccess}, throttledResults...)
+	events, err := s.db.FindLoginEventsByEmail(attempt.Email, results, now.Add(-failureWindow), maxAccountFailures+1)
+	if err != nil {
+		return 0, err
+	}
+	failures := 0
+	var lastFailure time.Time
+	for _, event := range otherAttempts(events, attempt, maxAccountFailures) {
+		if event.Result == models.LoginResultSuccess {
+			break
+		}
+		if failures == 0 {
+			lastFailure = event.CreatedAt
+		}
+		failures++
+	}
+	if failures > 0 {
+		wait := accountLockout
+		if failures < maxAccountFailures {
+			wait = time.Second << (failures - 1)
+		}
+		retryAfter = lastFailure.Add(wait).Sub(now)
+	}
+
+	events, err = s.db.FindLoginEventsByIP(attempt.IP, throttledResults, now.Add(-failureWindow), maxIPFailures+1)
+	if err != nil {
+		return 0, err
+	}
+	if ipFailures := otherAttempts(events, attempt, maxIPFailures); len(ipFailures) >= maxIPFailures {
+		// Blocked until the oldest of the failures leaves the window
+		oldest := ipFailures[len(ipFailures)-1]
+		if wait := oldest.CreatedAt.Add(failureWindow).Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return retryAfter, ErrTooManyAttempts
+	}
+	return 0, nil
+}
+
+// otherAttempts returns up to limit events other than the attempt itself
+func otherAttempts(events []*models.LoginEvent, attempt *models.LoginEvent, limit int) []*models.LoginEvent {
+	others := make([]*models.LoginEvent, 0, len(events))
+	for _, event := range events {
+		if event.ID != attempt.ID && len(others) < limit {
+			others = append(others, event)
+		}
+	}
+	return others
+}
+
+// FinishLogin records the result of an attempt started with StartLogin.
+// Failures to record are logged rather than failing the sign in.
+func (s *SecurityService) FinishLogin(attempt *models.LoginEvent, result string) {
+	if err := s.db.UpdateLoginEventResult(attempt.ID, result); err != nil {
+		log.Printf("Failed to record login result for %s: %v", attempt.Email, err)
+	}
+}
+
+// RecordLogin records a sign in attempt whose result is already known. The
+// user is nil when no account has the email. Failures to record are logged
+// rather than failing the sign in.
+func (s *SecurityService) RecordLogin(user *models.User, email, ip, userAgent, method, result string) {
+	event := newLoginEvent(user, email, ip, userAgent, method, result)
+	if err := s.db.CreateLoginEvent(event); err != nil {
+		log.Printf("Failed to record login event for %s: %v", event.Email, err)
+	}
+}
+
+func newLoginEvent(user *models.User, email, ip, userAgent, method, result string) *models.LoginEvent {
+	event := &models.LoginEvent{
+		Email:  normalizeEmail(email),
+		Method: method,
+		Result: result,
+		IP:     ip,
+	}
+	if user != nil {
+		event.UserID = user.ID
+		event.Email = normalizeEmail(user.Email)
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	event.UserAgent = userAgent
+	return event
+}
+
+// GetSecurityEvents returns a user's latest sign in attempts, newest first
+func (s *SecurityService) GetSecurityEvents(userID uint, limit int) ([]*models.LoginEvent, error) {
+	if limit <= 0 {
+		limit = defaultSecurityEvents
+	}
+	if limit > maxSecurityEvents {
+		limit = maxSecurityEvents
+	}
+	return s.db.FindLoginEventsByUserID(userID, limit)
+}
@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var ErrServiceAccountScope = errors.New("service account keys can only have the read-only or release scope")
+
+// serviceAccountEmailDomain is the domain of the placeholder emails of the
+// users backing service accounts. .invalid can never receive mail.
+const serviceAccountEmailDomain = "service-accounts.invalid"
+
+// ServiceAccountService manages organization service accounts, their access
+// keys and the apps they may release to. Only organization admins manage
+// them.
+type ServiceAccountService struct {
+	db               database.Database
+	orgService       *OrganizationService
+	accessKeyService *AccessKeyService
+}
+
+func NewServiceAccountService(db database.Database) *ServiceAccountService {
+	return &ServiceAccountService{
+		db:               db,
+		orgService:       NewOrganizationService(db),
+		accessKeyService: NewAccessKeyService(db),
+	}
+}
+
+// requireAdmin checks that a user is an admin of an organization
+func (s *ServiceAccountService) requireAdmin(userID uint, orgID uuid.UUID) error {
+	member, err := s.orgService.findMember(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role != models.RoleAdmin {
+		return utils.ErrAccessDenied
+	}
+	return nil
+}
+
+// findAccount returns a service account of an organization that has not been
+// deleted, after checking the user is an admin of the organization
+func (s *ServiceAccountService) findAccount(userID uint, orgID uuid.UUID, accountID uint) (*models.ServiceAccount, error) {
+	if err := s.requireAdmin(userID, orgID); err != nil {
+		return nil, err
+	}
+	account, err := s.db.FindServiceAccountByID(accountID)
+	if err != nil || account.OrganizationID != orgID || account.DeletedAt != nil {
+		return nil, utils.ErrNotFound
+	}
+	return account, nil
+}
+
+// CreateServiceAccount creates a service account as a developer member of an
+// organization
+func (s *ServiceAccountService) CreateServiceAccount(userID uint, orgID uuid.UUID, name string) (*models.ServiceAccount, error) {
+	if err := s.requireAdmin(userID, orgID); err != nil {
+		return nil, err
+	}
+
+	// The user has no password, so it cannot sign in
+	user := &models.User{
+		Username:         name,
+		Email:            fmt.Sprintf("%s@%s", uuid.NewString(), serviceAccountEmailDomain),
+		IsServiceAccount: true,
+	}
+	account := &models.ServiceAccount{
+		OrganizationID: orgID,
+		Name:           name,
+		CreatedBy:      userID,
+	}
+	member := &models.OrganizationMember{
+		OrganizationID: orgID,
+		Role:           models.RoleDeveloper,
+	}
+	if err := s.db.CreateServiceAccount(account, user, member); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *ServiceAccountService) GetServiceAccounts(userID uint, orgID uuid.UUID) ([]*models.ServiceAccount, error) {
+	if err := s.requireAdmin(userID, orgID); err != nil {
+		return nil, err
+	}
+	return s.db.FindServiceAccountsByOrganizationID(orgID)
+}
+
+// DeleteServiceAccount revokes a service account's keys and removes it from
+// the organization
+func (s *ServiceAccountService) DeleteServiceAccount(userID uint, orgID uuid.UUID, accountID uint) error {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return err
+	}
+	return s.db.DeleteServiceAccount(account, time.Now())
+}
+
+// CreateAccessKey creates a key for a service account. Keys default to the
+// release scope and cannot have the admin scope.
+func (s *ServiceAccountService) CreateAccessKey(userID uint, orgID uuid.UUID, accountID uint, name, scope string, expiresAt *time.Time) (*models.AccessKey, string, error) {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if scope == "" {
+		scope = models.ScopeRelease
+	}
+	if scope == models.ScopeAdmin {
+		return nil, "", ErrServiceAccountScope
+	}
+	return s.accessKeyService.CreateAccessKey(account.UserID, name, scope, expiresAt)
+}
+
+func (s *ServiceAccountService) GetAccessKeys(userID uint, orgID uuid.UUID, accountID uint) ([]*models.AccessKey, error) {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.accessKeyService.GetAccessKeys(account.UserID)
+}
+
+func (s *ServiceAccountService) RevokeAccessKey(userID uint, orgID uuid.UUID, accountID, keyID uint) error {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return err
+	}
+	if err := s.accessKeyService.RevokeAccessKey(account.UserID, keyID); err != nil {
+		// Keys of other users are reported as missing
+		if err == utils.ErrAccessDenied {
+			return utils.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AddGrant allows a service account to release to one of the admin's apps,
+// to a single deployment or to all of them when deploymentName is empty
+func (s *ServiceAccountService) AddGrant(userID uint, orgID uuid.UUID, accountID uint, appID, deploymentName string) (*models.ServiceAccountGrant, error) {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := authorizeApp(s.db, userID, appID)
+	if err != nil {
+		return nil, err
+	}
+	if deploymentName != "" {
+		if _, err := s.db.FindDeploymentByName(app.ID, deploymentName); err != nil {
+			return nil, utils.ErrNotFound
+		}
+	}
+
+	grants, err := s.db.FindServiceAccountGrants(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		if grant.AppID == app.ID && grant.DeploymentName == deploymentName {
+			return nil, utils.ErrAlreadyExists
+		}
+	}
+
+	grant := &models.ServiceAccountGrant{
+		ServiceAccountID: account.ID,
+		AppID:            app.ID,
+		DeploymentName:   deploymentName,
+		CreatedBy:        userID,
+	}
+	if err := s.db.CreateServiceAccountGrant(grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+func (s *ServiceAccountService) GetGrants(userID uint, orgID uuid.UUID, accountID uint) ([]*models.ServiceAccountGrant, error) {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.FindServiceAccountGrants(account.ID)
+}
+
+func (s *ServiceAccountService) RemoveGrant(userID uint, orgID uuid.UUID, accountID, grantID uint) error {
+	account, err := s.findAccount(userID, orgID, accountID)
+	if err != nil {
+		return err
+	}
+	grant, err := s.db.FindServiceAccountGrantByID(grantID)
+	if err != nil || grant.ServiceAccountID != account.ID {
+		return utils.ErrNotFound
+	}
+	return s.db.DeleteServiceAccountGrant(grant.ID)
+}
+
+// authorizeServiceAccount checks that a user is a service account granted
+// access to a deployment of an app. The account must still be a member of its
+// organization, checked as for people, and the app must still belong to one.
+func authorizeServiceAccount(db database.Database, userID uint, app *models.App, deploymentName string) error {
+	account, err := db.FindServiceAccountByUserID(userID)
+	if err != nil || account.DeletedAt != nil {
+		return utils.ErrAccessDenied
+	}
+	if _, err := NewOrganizationService(db).findMember(account.OrganizationID, userID); err != nil {
+		return utils.ErrAccessDenied
+	}
+	if _, err := db.FindOrganizationMember(account.OrganizationID, app.UserID); err != nil {
+		return utils.ErrAccessDenied
+	}
+
+	grants, err := db.FindServiceAccountGrants(account.ID)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		if grant.AppID == app.ID && (grant.DeploymentName == "" || grant.DeploymentName == deploymentName) {
+			return nil
+		}
+	}
+	return utils.ErrAccessDenied
+}
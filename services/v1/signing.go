@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/piyushsharma67/codepushserver/models"
+)
+
+var (
+	ErrInvalidSigningKey = errors.New("signing key must be a PEM encoded RSA public key")
+	ErrSignatureRequired = errors.New("releases of this deployment must be signed")
+	ErrInvalidSignature  = errors.New("release signature does not match the package")
+)
+
+// validateSigningKey checks that a key can be used to verify release signatures.
+// An empty key disables signature verification.
+func validateSigningKey(publicKey string) error {
+	if publicKey == "" {
+		return nil
+	}
+	if _, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey)); err != nil {
+		return ErrInvalidSigningKey
+	}
+	return nil
+}
+
+// signingKey returns the key releases of a deployment are verified with. A
+// key registered on the deployment takes precedence over the app's key.
+func signingKey(app *models.App, deployment *models.Deployment) string {
+	if deployment.SigningPublicKey != "" {
+		return deployment.SigningPublicKey
+	}
+	return app.SigningPublicKey
+}
+
+// verifyReleaseSignature checks the signature of a package released to a
+// deployment. The signature is the JWT produced by the CodePush CLI's code
+// signing feature: an RS256 token whose contentHash claim is the package hash.
+// Deployments without a signing key accept any signature, which clients still
+// verify against the key bundled in the app.
+func verifyReleaseSignature(app *models.App, deployment *models.Deployment, signature, packageHash string) error {
+	publicKey := signingKey(app, deployment)
+	if publicKey == "" {
+		return nil
+	}
+	if signature == "" {
+		return ErrSignatureRequired
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey))
+	if err != nil {
+		return ErrInvalidSigningKey
+	}
+
+	token, err := jwt.Parse(signature, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrInvalidSignature
+		}
+		return key, nil
+	})
+	if err != nil || !token.Valid {
+		return ErrInvalidSignature
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["contentHash"] != packageHash {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+// TokenPair is the access and refresh token returned on sign in
+type TokenPair struct {
+	AccessToken      string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// TokenService issues access and refresh tokens and tracks their revocation
+type TokenService struct {
+	db         database.Database
+	jwtService *services.JWTService
+}
+
+func NewTokenService(db database.Database, jwtService *services.JWTService) *TokenService {
+	return &TokenService{db: db, jwtService: jwtService}
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientInfo describes the device a user signs in from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// IssueTokens signs a user in, starting a new session. The session ID is also
+// the family ID of its refresh tokens.
+func (s *TokenService) IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error) {
+	userAgent := client.UserAgent
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         client.IP,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.jwtService.RefreshExpiry()),
+	}
+	if err := s.db.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, session.ID)
+}
+
+func (s *TokenService) issue(user *models.User, sessionID string) (*TokenPair, error) {
+	accessToken, expiresAt, err := s.jwtService.GenerateToken(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := utils.GenerateRandomString(32)
+	refresh := &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashRefreshToken(secret),
+		ExpiresAt: time.Now().Add(s.jwtService.RefreshExpiry()),
+	}
+	if err := s.db.CreateRefreshToken(refresh); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     secret,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, keeping its session
+// alive. A token that was already exchanged revokes its whole session,
+// logging out both the attacker and the legitimate client, which has to sign
+// in again.
+func (s *TokenService) Refresh(refreshToken, ip string) (*TokenPair, error) {
+	token, err := s.db.FindRefreshTokenByHash(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil || now.After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Refresh tokens without a session are rejected
+	session, err := s.db.FindSessionByID(token.FamilyID)
+	if err != nil || session.UserID != token.UserID || session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	marked, err := s.db.MarkRefreshTokenUsed(token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		if err := s.db.RevokeSession(session.ID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := s.db.FindUserByID(token.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokens, err := s.issue(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.ExtendSession(session.ID, ip, now, tokens.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Logout ends the session of an access token and, if given, the session of a
+// refresh token
+func (s *TokenService) Logout(claims *services.TokenClaims, refreshToken string) error {
+	now := time.Now()
+	if refreshToken != "" {
+		token, err := s.db.FindRefreshTokenByHash(hashRefreshToken(refreshToken))
+		if err == nil && token.UserID == claims.UserID {
+			if err := s.db.RevokeSession(token.FamilyID, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := s.db.RevokeSession(claims.SessionID, now); err != nil {
+			return err
+		}
+	}
+
+	if claims.ID == "" {
+		return nil
+	}
+	return s.db.RevokeToken(&models.RevokedToken{
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt,
+	})
+}
+
+// LogoutAll ends every session of a user, which invalidates every access and
+// refresh token issued to them so far
+func (s *TokenService) LogoutAll(userID uint) error {
+	if _, err := s.db.FindUserByID(userID); err != nil {
+		return utils.ErrNotFound
+	}
+
+	now := time.Now()
+	if err := s.db.RevokeUserSessions(userID, now); err != nil {
+		return err
+	}
+	return s.db.RevokeUserRefreshTokens(userID, now)
+}
+
+// IsRevoked reports whether a validated access token has been revoked, either
+// individually or by ending its session, and records the use of its session
+// from the given IP address. Tokens without a session ID are rejected.
+func (s *TokenService) IsRevoked(claims *services.TokenClaims, ip string) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := s.db.IsTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if claims.SessionID == "" {
+		return true, nil
+	}
+	session, err := s.db.FindSessionByID(claims.SessionID)
+	if err != nil {
+		return true, nil
+	}
+	now := time.Now()
+	if session.UserID != claims.UserID || session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return true, nil
+	}
+
+	if session.IP != ip || now.Sub(session.LastUsedAt) > lastUsedInterval {
+		if err := s.db.TouchSession(session.ID, ip, now); err != nil {
+			log.Printf("Failed to record use of session %s: %v", session.ID, err)
+		}
+	}
+	return false, nil
+}
+
+// GetSessions returns the active sessions of a user, most recently used first
+func (s *TokenService) GetSessions(userID uint) ([]*models.Session, error) {
+	return s.db.FindActiveSessionsByUserID(userID)
+}
+
+// RevokeSession ends one of a user's sessions, logging out the device.
+// Sessions of other users are reported as missing, so their IDs cannot be
+// probed.
+func (s *TokenService) RevokeSession(userID uint, sessionID string) error {
+	session, err := s.db.FindSessionByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return utils.ErrNotFound
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return s.db.RevokeSession(session.ID, time.Now())
+}
@@ -0,0 +1,345 @@
+package v1
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/config"
+	"github.com/piyushsharma67/codepushserver/database"
+	"github.com/piyushsharma67/codepushserver/models"
+	"github.com/piyushsharma67/codepushserver/services"
+	"github.com/piyushsharma67/codepushserver/utils"
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrInvalidChallenge     = errors.New("login challenge is invalid or has expired")
+	ErrTwoFactorRequired    = errors.New("organization requires two-factor authentication")
+)
+
+const (
+	// loginChallengeExpiry is how long a user has to enter their code after
+	// their password
+	loginChallengeExpiry = 5 * time.Minute
+	// maxChallengeAttempts limits guessing codes for one challenge
+	maxChallengeAttempts = 5
+
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// LoginResult is the outcome of a successful password or single sign-on
+// check: tokens, or a challenge to complete with a second factor for users
+// with two-factor authentication
+type LoginResult struct {
+	User               *models.User
+	Tokens             *TokenPair
+	ChallengeToken     string
+	ChallengeExpiresAt time.Time
+}
+
+// TwoFactorService manages TOTP two-factor authentication and completes
+// logins that need it
+type TwoFactorService struct {
+	db           database.Database
+	tokenService *TokenService
+	issuer       string
+}
+
+func NewTwoFactorService(db database.Database, jwtService *services.JWTService, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		db:           db,
+		tokenService: NewTokenService(db, jwtService),
+		issuer:       cfg.TOTPIssuer,
+	}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeRecoveryCode accepts recovery codes with or without the dash and
+// in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// Status reports whether a user has two-factor authentication enabled and how
+// many unused recovery codes they have left
+func (s *TwoFactorService) Status(userID uint) (bool, int, error) {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return false, 0, utils.ErrNotFound
+	}
+	if !user.TOTPEnabled {
+		return false, 0, nil
+	}
+
+	codes, err := s.db.FindRecoveryCodesByUserID(userID)
+	if err != nil {
+		return false, 0, err
+	}
+	remaining := 0
+	for _, code := range codes {
+		if code.UsedAt == nil {
+			remaining++
+		}
+	}
+	return true, remaining, nil
+}
+
+// Enroll generates a new TOTP secret for a user and returns it along with its
+// provisioning URI. Two-factor authentication is enabled once a code from the
+// authenticator is confirmed.
+func (s *TwoFactorService) Enroll(userID uint) (string, string, error) {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return "", "", utils.ErrNotFound
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret = secret
+	if err := s.db.UpdateUser(user); err != nil {
+		return "", "", err
+	}
+
+	return secret, utils.TOTPProvisioningURI(s.issuer, user.Email, secret), nil
+}
+
+// Confirm enables two-factor authentication with a code from the enrolled
+// authenticator and returns the user's recovery codes
+func (s *TwoFactorService) Confirm(userID uint, code string) ([]string, error) {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	// Read the user again, as verifying the code updated it
+	if user, err = s.db.FindUserByID(userID); err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	if err := s.db.UpdateUser(user); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(userID)
+}
+
+// Disable turns off two-factor authentication after checking a code, unless
+// an organization the user belongs to requires it
+func (s *TwoFactorService) Disable(userID uint, code string) error {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return utils.ErrNotFound
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	orgs, err := s.db.FindOrganizationsByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		if org.Require2FA {
+			return ErrTwoFactorRequired
+		}
+	}
+
+	if err := s.verifyCode(user, code); err != nil {
+		return err
+	}
+
+	if user, err = s.db.FindUserByID(userID); err != nil {
+		return err
+	}
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	if err := s.db.UpdateUser(user); err != nil {
+		return err
+	}
+	return s.db.ReplaceRecoveryCodes(userID, nil)
+}
+
+// RegenerateRecoveryCodes replaces a user's recovery codes after checking a
+// code from their authenticator
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.db.FindUserByID(userID)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(userID)
+}
+
+func (s *TwoFactorService) generateRecoveryCodes(userID uint) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]*models.RecoveryCode, 0, recoveryCodeCount)
+	seen := make(map[string]bool)
+	for len(codes) < recoveryCodeCount {
+		b := make([]byte, 10)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, err
+			}
+			b[i] = recoveryCodeAlphabet[n.Int64()]
+		}
+		code := string(b)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		plain = append(plain, code[:5]+"-"+code[5:])
+		codes = append(codes, &models.RecoveryCode{UserID: userID, CodeHash: hashSecret(code)})
+	}
+
+	if err := s.db.ReplaceRecoveryCodes(userID, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// verifyTOTP checks a code from the user's authenticator. Each code is
+// accepted once, so an intercepted code cannot be replayed.
+func (s *TwoFactorService) verifyTOTP(user *models.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	accepted, err := s.db.UpdateTOTPLastStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyCode checks a code from the user's authenticator or one of their
+// recovery codes, which is then used up
+func (s *TwoFactorService) verifyCode(user *models.User, code string) error {
+	if err := s.verifyTOTP(user, code); err != ErrInvalidTwoFactorCode {
+		return err
+	}
+
+	used, err := s.db.UseRecoveryCode(user.ID, hashSecret(normalizeRecoveryCode(code)), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// BeginLogin signs in a user whose first factor has been checked, returning
+// tokens directly or a challenge if the user has two-factor authentication.
+// The client is the device the session is for.
+func (s *TwoFactorService) BeginLogin(user *models.User, client ClientInfo) (*LoginResult, error) {
+	if !user.TOTPEnabled {
+		tokens, err := s.tokenService.IssueTokens(user, client)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Tokens: tokens}, nil
+	}
+
+	secret := utils.GenerateRandomString(32)
+	challenge := &models.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: hashSecret(secret),
+		ExpiresAt: time.Now().Add(loginChallengeExpiry),
+	}
+	if err := s.db.CreateLoginChallenge(challenge); err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, ChallengeToken: secret, ChallengeExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// ChallengeUser returns the user a pending login challenge is for
+func (s *TwoFactorService) ChallengeUser(challengeToken string) (*models.User, error) {
+	challenge, err := s.db.FindLoginChallengeByHash(hashSecret(challengeToken))
+	if err != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrInvalidChallenge
+	}
+	user, err := s.db.FindUserByID(challenge.UserID)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	return user, nil
+}
+
+// CompleteLogin completes a login challenge with a code from the user's
+// authenticator or a recovery code. A challenge is dropped after too many
+// wrong codes, so the password has to be entered again.
+func (s *TwoFactorService) CompleteLogin(challengeToken, code string, client ClientInfo) (*TokenPair, *models.User, error) {
+	challenge, err := s.db.FindLoginChallengeByHash(hashSecret(challengeToken))
+	if err != nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	user, err := s.db.FindUserByID(challenge.UserID)
+	if err != nil || !user.TOTPEnabled {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	if err := s.verifyCode(user, code); err != nil {
+		if err == ErrInvalidTwoFactorCode {
+			if err := s.db.IncrementLoginChallengeAttempts(challenge.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, err
+	}
+
+	deleted, err := s.db.DeleteLoginChallenge(challenge.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !deleted {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	tokens, err := s.tokenService.IssueTokens(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokens, user, nil
+}
@@ -71,6 +71,19 @@ func (s *UserService) CreateApp(userID uint, name, description string) (*models.
 		return nil, err
 	}
 
+	// Every app starts with the standard Staging and Production deployments
+	for _, name := range models.DefaultDeployments {
+		deployment := &models.Deployment{
+			AppID: app.ID,
+			Name:  name,
+			Key:   generateDeploymentKey(),
+		}
+		if err := s.db.CreateDeployment(deployment); err != nil {
+			return nil, err
+		}
+		app.Deployments = append(app.Deployments, *deployment)
+	}
+
 	return app, nil
 }
 
@@ -105,6 +118,26 @@ func (s *UserService) UpdateApp(userID uint, appID string) (*models.App, error)
 	return app, nil
 }
 
+// SetAppSigningKey registers the public key release signatures of the app's
+// deployments are verified with. An empty key disables verification.
+func (s *UserService) SetAppSigningKey(userID uint, appID, publicKey string) (*models.App, error) {
+	app, err := s.GetApp(userID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSigningKey(publicKey); err != nil {
+		return nil, err
+	}
+
+	app.SigningPublicKey = publicKey
+	if err := s.db.UpdateApp(app); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
 func (s *UserService) DeleteApp(userID uint, appID string) error {
 	app, err := s.db.FindAppByID(appID)
 	if err != nil {
@@ -120,4 +153,4 @@ func (s *UserService) DeleteApp(userID uint, appID string) error {
 
 func (s *UserService) GetAllApps(userID uint) ([]*models.App, error) {
 	return s.db.FindAppsByUserID(userID)
-} 
\ No newline at end of file
+}
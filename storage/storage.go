@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/piyushsharma67/codepushserver/config"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores update packages and other binary artifacts by key.
+// Keys are slash-separated paths such as "packages/<hash>.zip".
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob
+	Put(key string, r io.Reader, size int64) error
+	// Get opens the blob stored under key. It returns ErrNotFound if it does not exist.
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(key string) error
+	// Stat returns information about a blob. It returns ErrNotFound if it does not exist.
+	Stat(key string) (*BlobInfo, error)
+	// SignedURL returns a URL clients can use to download the blob until expiry
+	SignedURL(key string, expiry time.Duration) (string, error)
+}
+
+// NewBlobStore creates a new blob store based on the configuration
+func NewBlobStore(config *config.Config) (BlobStore, error) {
+	switch config.StorageType {
+	case "local":
+		return NewLocalStore(config.StoragePath, strings.TrimSuffix(config.ServerURL, "/")+"/storage")
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:            config.S3Endpoint,
+			Region:              config.S3Region,
+			Bucket:              config.S3Bucket,
+			AccessKey:           config.S3AccessKey,
+			SecretKey:           config.S3SecretKey,
+			UsePathStyle:        config.S3UsePathStyle,
+			ForbiddenAsNotFound: config.S3ForbiddenAsNotFound,
+		})
+	default:
+		return NewLocalStore(config.StoragePath, strings.TrimSuffix(config.ServerURL, "/")+"/storage")
+	}
+}
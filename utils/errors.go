@@ -3,7 +3,8 @@ package utils
 import "errors"
 
 var (
-	ErrAccessDenied = errors.New("access denied")
-	ErrNotFound     = errors.New("not found")
-	ErrInternal     = errors.New("internal server error")
+	ErrAccessDenied  = errors.New("access denied")
+	ErrNotFound      = errors.New("not found")
+	ErrInternal      = errors.New("internal server error")
+	ErrAlreadyExists = errors.New("already exists")
 )
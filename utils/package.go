@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SignatureFileName is the file holding the code signing JWT of a package
+const SignatureFileName = ".codepushrelease"
+
+// IsSignatureFile reports whether a zip entry is a code signing signature
+func IsSignatureFile(name string) bool {
+	return path.Base(name) == SignatureFileName
+}
+
+// isIgnoredPackageFile reports whether a zip entry is excluded from the
+// package hash, mirroring the files skipped by the CodePush CLI. The
+// signature is excluded since it signs the hash itself.
+func isIgnoredPackageFile(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || base == ".DS_Store" || IsSignatureFile(name)
+}
+
+// PackageManifest returns the SHA-256 of every file in a zipped update
+// package, keyed by its slash-separated path inside the archive
+func PackageManifest(r *zip.Reader) (map[string]string, error) {
+	manifest := make(map[string]string)
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() || isIgnoredPackageFile(file.Name) {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		manifest[file.Name] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return manifest, nil
+}
+
+// HashManifest computes the package hash of a manifest: the SHA-256 of the
+// JSON encoded, sorted list of "path:hash" entries, as the CodePush CLI does
+func HashManifest(manifest map[string]string) string {
+	entries := make([]string, 0, len(manifest))
+	for name, hash := range manifest {
+		entries = append(entries, name+":"+hash)
+	}
+	sort.Strings(entries)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(entries)
+
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// packageRoot returns the directory holding the package contents: the single
+// top level directory of the archive if there is one, otherwise the root
+func packageRoot(r *zip.Reader) string {
+	root := ""
+	for _, file := range r.File {
+		if isIgnoredPackageFile(file.Name) {
+			continue
+		}
+		i := strings.IndexByte(file.Name, '/')
+		if i < 0 {
+			return ""
+		}
+		if root != "" && root != file.Name[:i+1] {
+			return ""
+		}
+		root = file.Name[:i+1]
+	}
+	return root
+}
+
+// ReadPackageSignature returns the signature embedded in a package, if any
+func ReadPackageSignature(r *zip.Reader) (string, error) {
+	name := packageRoot(r) + SignatureFileName
+	for _, file := range r.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		signature, err := io.ReadAll(rc)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(signature)), nil
+	}
+	return "", nil
+}
+
+// WriteSignedPackage copies a package to w with the given signature stored
+// next to its contents, replacing any existing signature
+func WriteSignedPackage(r *zip.Reader, w io.Writer, signature string) error {
+	writer := zip.NewWriter(w)
+	for _, file := range r.File {
+		if IsSignatureFile(file.Name) {
+			continue
+		}
+		if err := writer.Copy(file); err != nil {
+			return err
+		}
+	}
+
+	f, err := writer.Create(packageRoot(r) + SignatureFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, signature); err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,366 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSemver = errors.New("invalid semver")
+
+// Version is a parsed semantic version. Missing minor or patch components
+// are treated as zero, so app versions like "1.2" are accepted.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or greater than o
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+// comparePrerelease orders prerelease tags; a version without one is greater
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if len(as) < len(bs) {
+		return -1
+	}
+	return 1
+}
+
+// ParseVersion parses a version such as "1.2.3", "1.2" or "v1.2.3-beta.1"
+func ParseVersion(s string) (Version, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if p.major < 0 {
+		return Version{}, ErrInvalidSemver
+	}
+	return p.floor(), nil
+}
+
+// partialVersion is a version in a range where trailing components may be
+// missing or wildcards ("1.2", "1.x", "*"), represented by -1
+type partialVersion struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+func parsePartial(s string) (partialVersion, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "="), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	p := partialVersion{major: -1, minor: -1, patch: -1}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		p.prerelease = s[i+1:]
+		s = s[:i]
+	}
+	if s == "" {
+		return p, ErrInvalidSemver
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, ErrInvalidSemver
+	}
+	fields := []*int{&p.major, &p.minor, &p.patch}
+	wildcard := false
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			wildcard = true
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || wildcard {
+			return p, ErrInvalidSemver
+		}
+		*fields[i] = n
+	}
+	return p, nil
+}
+
+// floor returns the lowest version matched by the partial version
+func (p partialVersion) floor() Version {
+	v := Version{Major: p.major, Minor: p.minor, Patch: p.patch, Prerelease: p.prerelease}
+	if v.Major < 0 {
+		v.Major = 0
+	}
+	if v.Minor < 0 {
+		v.Minor = 0
+	}
+	if v.Patch < 0 {
+		v.Patch = 0
+	}
+	return v
+}
+
+// next returns the first version above everything matched by the partial
+// version, e.g. 1.3.0 for "1.2" and 2.0.0 for "1". ok is false for "*".
+func (p partialVersion) next() (v Version, ok bool) {
+	switch {
+	case p.major < 0:
+		return Version{}, false
+	case p.minor < 0:
+		return Version{Major: p.major + 1}, true
+	case p.patch < 0:
+		return Version{Major: p.major, Minor: p.minor + 1}, true
+	default:
+		return Version{Major: p.major, Minor: p.minor, Patch: p.patch + 1}, true
+	}
+}
+
+func (p partialVersion) complete() bool {
+	return p.patch >= 0
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Range is a parsed version range in the syntax used by node-semver, which
+// CodePush uses for target binary versions: exact versions, x-ranges
+// ("1.2.x", "1.x", "*"), tilde and caret ranges ("~1.2.3", "^2.0.0"),
+// comparators (">=1.0 <1.5"), hyphen ranges ("1.0 - 1.4") and "||" unions.
+type Range struct {
+	sets [][]comparator
+}
+
+// ParseRange parses a version range
+func ParseRange(s string) (*Range, error) {
+	r := &Range{}
+	for _, alternative := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(alternative)
+		if err != nil {
+			return nil, err
+		}
+		r.sets = append(r.sets, set)
+	}
+	return r, nil
+}
+
+// ValidRange reports whether s is a valid version range
+func ValidRange(s string) bool {
+	_, err := ParseRange(s)
+	return err == nil
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	fields := strings.Fields(s)
+
+	// Hyphen range: "1.0.0 - 1.5.0"
+	if len(fields) == 3 && fields[1] == "-" {
+		from, err := parsePartial(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parsePartial(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		set := []comparator{{op: ">=", v: from.floor()}}
+		if to.complete() {
+			set = append(set, comparator{op: "<=", v: to.floor()})
+		} else if next, ok := to.next(); ok {
+			set = append(set, comparator{op: "<", v: next})
+		}
+		return set, nil
+	}
+
+	// Allow a space between an operator and its version, e.g. ">= 1.0"
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		if strings.Trim(fields[i], "<>=~^") == "" && i+1 < len(fields) {
+			tokens = append(tokens, fields[i]+fields[i+1])
+			i++
+			continue
+		}
+		tokens = append(tokens, fields[i])
+	}
+	if len(tokens) == 0 {
+		return []comparator{}, nil // "" matches every version, like "*"
+	}
+
+	var set []comparator
+	for _, token := range tokens {
+		comparators, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comparators...)
+	}
+	return set, nil
+}
+
+// parseComparator expands a single range token into primitive comparators
+func parseComparator(token string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	p, err := parsePartial(token[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	floor := p.floor()
+	next, bounded := p.next()
+
+	switch op {
+	case "~":
+		// ~1.2.3 := >=1.2.3 <1.3.0, ~1 := >=1.0.0 <2.0.0
+		if p.minor >= 0 {
+			next, bounded = Version{Major: floor.Major, Minor: floor.Minor + 1}, true
+		}
+	case "^":
+		// ^1.2.3 := >=1.2.3 <2.0.0, ^0.2.3 := >=0.2.3 <0.3.0, ^0.0.3 := >=0.0.3 <0.0.4
+		switch {
+		case p.major > 0 || p.minor < 0:
+			next, bounded = p.truncate(1).next()
+		case p.minor > 0 || p.patch < 0:
+			next, bounded = p.truncate(2).next()
+		}
+	case ">":
+		if !p.complete() {
+			if !bounded {
+				// ">*" matches nothing
+				return []comparator{{op: "<", v: Version{}}}, nil
+			}
+			return []comparator{{op: ">=", v: next}}, nil
+		}
+		return []comparator{{op: ">", v: floor}}, nil
+	case ">=":
+		return []comparator{{op: ">=", v: floor}}, nil
+	case "<":
+		return []comparator{{op: "<", v: floor}}, nil
+	case "<=":
+		if !p.complete() {
+			if !bounded {
+				return []comparator{}, nil
+			}
+			return []comparator{{op: "<", v: next}}, nil
+		}
+		return []comparator{{op: "<=", v: floor}}, nil
+	default:
+		if p.complete() {
+			return []comparator{{op: "=", v: floor}}, nil
+		}
+	}
+
+	if !bounded {
+		return []comparator{}, nil
+	}
+	return []comparator{{op: ">=", v: floor}, {op: "<", v: next}}, nil
+}
+
+// truncate keeps only the first n components of a partial version
+func (p partialVersion) truncate(n int) partialVersion {
+	t := partialVersion{major: p.major, minor: -1, patch: -1}
+	if n > 1 {
+		t.minor = p.minor
+	}
+	return t
+}
+
+// Satisfies reports whether v is within the range
+func (r *Range) Satisfies(v Version) bool {
+	for _, set := range r.sets {
+		if setMatches(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAbove reports whether v is lower than every version in the range
+func (r *Range) IsAbove(v Version) bool {
+	for _, set := range r.sets {
+		if setMatches(set, v) {
+			return false
+		}
+		preceded := false
+		for _, c := range set {
+			lower := c.op == ">" || c.op == ">=" || c.op == "="
+			if lower && !c.matches(v) && v.Compare(c.v) <= 0 {
+				preceded = true
+				break
+			}
+		}
+		if !preceded {
+			return false
+		}
+	}
+	return true
+}
+
+func setMatches(set []comparator, v Version) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
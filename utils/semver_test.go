@@ -0,0 +1,166 @@
+package utils
+
+import "testing"
+
+// satisfies parses a version and a range and reports whether the range
+// matches the version
+func satisfies(version, rangeExpr string) (bool, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	r, err := ParseRange(rangeExpr)
+	if err != nil {
+		return false, err
+	}
+	return r.Satisfies(v), nil
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		version, rangeExpr string
+		want               bool
+	}{
+		// Exact versions, with missing components treated as zero
+		{"1.2.3", "1.2.3", true},
+		{"1.2.4", "1.2.3", false},
+		{"1.2", "1.2.0", true},
+		{"v1.2.3", "=1.2.3", true},
+		{"1.2.3+build.5", "1.2.3", true},
+
+		// X-ranges
+		{"1.2.9", "1.2.x", true},
+		{"1.3.0", "1.2.x", false},
+		{"1.9.0", "1.x", true},
+		{"2.0.0", "1.x", false},
+		{"1.2.5", "1.2", true},
+		{"0.0.1", "*", true},
+		{"9.9.9", "", true},
+
+		// Tilde ranges
+		{"1.2.3", "~1.2.3", true},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.2.2", "~1.2.3", false},
+		{"1.9.0", "~1", true},
+		{"2.0.0", "~1", false},
+
+		// Caret ranges
+		{"1.9.9", "^1.2.3", true},
+		{"2.0.0", "^1.2.3", false},
+		{"0.2.9", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+		{"0.9.0", "^0.x", true},
+
+		// Comparators, with or without a space after the operator
+		{"1.4.9", ">=1.0 <1.5", true},
+		{"1.5.0", ">=1.0 <1.5", false},
+		{"1.0.0", ">= 1.0.0", true},
+		{"1.0.0", ">1.0.0", false},
+		{"1.3.0", ">1.2", true},
+		{"1.2.9", ">1.2", false},
+		{"1.2.9", "<=1.2", true},
+		{"1.3.0", "<=1.2", false},
+		{"1.0.0", ">*", false},
+
+		// Hyphen ranges
+		{"1.0.0", "1.0.0 - 1.4.0", true},
+		{"1.4.0", "1.0.0 - 1.4.0", true},
+		{"1.4.1", "1.0.0 - 1.4.0", false},
+		{"1.4.9", "1.0 - 1.4", true},
+		{"1.5.0", "1.0 - 1.4", false},
+
+		// Unions
+		{"1.5.0", "^1.0.0 || ^3.0.0", true},
+		{"3.1.0", "^1.0.0 || ^3.0.0", true},
+		{"2.0.0", "^1.0.0 || ^3.0.0", false},
+
+		// Prereleases sort below their release
+		{"1.2.3-beta.1", "1.2.3", false},
+		{"1.2.3-beta.1", "<1.2.3", true},
+		{"1.2.3-beta.2", ">1.2.3-beta.1", true},
+		{"1.2.3-beta.10", ">1.2.3-beta.2", true},
+		{"1.2.3-alpha", "<1.2.3-1", false},
+		{"1.2.3-rc.1", "1.2.3-rc.1", true},
+	}
+
+	for _, tt := range tests {
+		got, err := satisfies(tt.version, tt.rangeExpr)
+		if err != nil {
+			t.Errorf("satisfies(%q, %q) returned error: %v", tt.version, tt.rangeExpr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("satisfies(%q, %q) = %v, want %v", tt.version, tt.rangeExpr, got, tt.want)
+		}
+	}
+}
+
+func TestSatisfiesInvalid(t *testing.T) {
+	tests := []struct {
+		version, rangeExpr string
+	}{
+		{"", "1.0.0"},
+		{"1.2.3.4", "1.0.0"},
+		{"x", "1.0.0"},
+		{"1.0.0", "1.2.3.4"},
+		{"1.0.0", "1.x.3"},
+		{"1.0.0", "abc"},
+		{"1.0.0", "1.0 - abc"},
+		{"1.0.0", "-1.0.0"},
+	}
+
+	for _, tt := range tests {
+		if _, err := satisfies(tt.version, tt.rangeExpr); err == nil {
+			t.Errorf("satisfies(%q, %q) returned no error", tt.version, tt.rangeExpr)
+		}
+	}
+}
+
+func TestRangeIsAbove(t *testing.T) {
+	tests := []struct {
+		rangeExpr, version string
+		above              bool
+	}{
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", "1.2.2", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.x", "1.3.0", false},
+		{"1.2.x", "1.1.9", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.2.2", true},
+		{">=1.0.0", "9.0.0", false},
+		{">=1.0.0", "0.9.0", true},
+		{"<2.0.0", "2.0.0", false},
+		{"<2.0.0", "0.0.1", false},
+		{"*", "1.0.0", false},
+		{"1.0.0 - 1.4.0", "1.4.1", false},
+		{"1.0.0 - 1.4.0", "0.9.9", true},
+
+		// A union is not above a version between its alternatives
+		{"^1.0.0 || ^3.0.0", "2.0.0", false},
+		{"^1.0.0 || ^3.0.0", "4.0.0", false},
+		{"^1.0.0 || ^3.0.0", "0.5.0", true},
+
+		// Prereleases
+		{"1.2.3", "1.2.3-beta.1", true},
+		{"<1.2.3", "1.2.3-beta.1", false},
+		{"1.2.3-beta.1", "1.2.3", false},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRange(tt.rangeExpr)
+		if err != nil {
+			t.Fatalf("ParseRange(%q) returned error: %v", tt.rangeExpr, err)
+		}
+		v, err := ParseVersion(tt.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) returned error: %v", tt.version, err)
+		}
+		if got := r.IsAbove(v); got != tt.above {
+			t.Errorf("ParseRange(%q).IsAbove(%q) = %v, want %v", tt.rangeExpr, tt.version, got, tt.above)
+		}
+	}
+}